@@ -1,18 +1,118 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
 
 	"github.com/marc-barry/domaininfo/pkg/cmd/domaininfo"
+	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/types"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	opts := types.Options{}
+
+	resolvers := flag.String("resolver", "", "comma separated addresses of the upstream resolvers (default from the resolv.conf file)")
+	flag.StringVar(&opts.Strategy, "strategy", "", "strategy for using multiple upstream resolvers: failover, round-robin or race (default failover)")
+	flag.StringVar(&opts.ResolvConf, "resolv-conf", types.DEFAULTRESOLVCONF, "path of the resolv.conf file used when no resolver is given")
+	flag.IntVar(&opts.Port, "port", types.DEFAULTPORT, "port of the upstream resolver when the address doesn't include one, and of the nameservers queried by --iterative")
+	flag.DurationVar(&opts.Timeout, "timeout", 0, "timeout for each DNS query (default from the resolv.conf file or 5s)")
+	flag.DurationVar(&opts.Deadline, "deadline", 0, "overall deadline for all DNS queries (default none)")
+	flag.IntVar(&opts.Retries, "retries", types.DEFAULTRETRIES, "number of times a failed DNS query is retried, -1 for the attempts of the resolv.conf file or else 0")
+	flag.DurationVar(&opts.RetryBackoff, "retry-backoff", types.DEFAULTRETRYBACKOFF, "delay before the first retry, doubled for each further retry")
+	flag.DurationVar(&opts.RetryMaxBackoff, "retry-max-backoff", types.DEFAULTRETRYMAXBACKOFF, "maximum delay between retries")
+	retryRcodes := flag.String("retry-rcodes", "SERVFAIL", "comma separated rcodes for which a DNS query is retried")
+	flag.BoolVar(&opts.IPv4Only, "ipv4-only", false, "only look up IPv4 addresses")
+	flag.BoolVar(&opts.IPv6Only, "ipv6-only", false, "only look up IPv6 addresses")
+	udpSize := flag.Uint("edns-size", types.DEFAULTUDPSIZE, "EDNS0 UDP buffer size advertised on queries (0 disables EDNS0)")
+	flag.StringVar(&opts.DoHMethod, "doh-method", "GET", "HTTP method used for DNS-over-HTTPS resolvers (GET or POST)")
+	flag.StringVar(&opts.TLSServerName, "tls-server-name", "", "server name used for SNI and certificate verification of DNS-over-TLS resolvers")
+	tlsPins := flag.String("tls-pins", "", "comma separated base64 SHA-256 digests of the public keys trusted for DNS-over-TLS resolvers")
+	flag.IntVar(&opts.Concurrency, "concurrency", types.DEFAULTCONCURRENCY, "number of DNS queries in flight at once, shared by all the lookups of all the domains")
+	flag.IntVar(&opts.Workers, "workers", types.DEFAULTWORKERS, "number of domains looked up at once when reading domains from a file or stdin")
+	flag.IntVar(&opts.MaxAddresses, "max-addresses", types.DEFAULTMAXADDRESSES, "number of addresses of a prefix looked up before sampling one address per block")
+	file := flag.String("f", "", "file with one domain per line to look up, writing one line of JSON per domain")
+	flag.BoolVar(&opts.DNSCache, "dns-cache", true, "cache DNS answers in memory for their TTL")
+	flag.StringVar(&opts.CacheDir, "cache-dir", defaultCacheDir(), "directory caching the ASN lookups")
+	flag.DurationVar(&opts.CacheMaxAge, "cache-max-age", types.DEFAULTCACHEMAXAGE, "age after which cached ASN lookups are looked up again")
+	flag.BoolVar(&opts.NoCache, "no-cache", false, "don't read or write the cached ASN lookups")
+	flag.BoolVar(&opts.RefreshCache, "refresh", false, "look up the ASNs again and replace the cached lookups")
+	flag.BoolVar(&opts.CheckSOA, "soa", false, "query the SOA record from each nameserver of the zone directly and compare their serials")
+	flag.BoolVar(&opts.CheckDelegation, "delegation", false, "query the parent zone's servers and each nameserver directly to find lame nameservers and parent/child NS mismatches")
+	flag.BoolVar(&opts.Iterative, "iterative", false, "resolve names from the root servers instead of using upstream resolvers, tracing the referrals followed")
+	flag.StringVar(&opts.RootHints, "root-hints", "", "root hints file in the named.root format used by --iterative (default the built-in root servers)")
+	flag.StringVar(&opts.ASNDataset, "asn-dataset", "", "prefix to AS dataset in the CAIDA pfx2as format listing the prefixes originated by each ASN")
+	flag.BoolVar(&opts.Debug, "debug", false, "log each DNS query and the transport used to stderr")
+	flag.Parse()
+
+	opts.UDPSize = uint16(*udpSize)
+	rcodes, err := dnsutil.ParseRcodes(*retryRcodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts.RetryRcodes = rcodes
+	if *resolvers != "" {
+		opts.Resolvers, err = dnsutil.ParseResolvers(*resolvers)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *tlsPins != "" {
+		opts.TLSPins = strings.Split(*tlsPins, ",")
+	}
+
+	if *file == "" && flag.NArg() < 1 {
 		log.Fatal("Requires at least one command line argument")
 	}
 
-	if err := domaininfo.RunCommand(os.Args[1]); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The first interrupt cancels the DNS queries while a second one, such as when blocked reading stdin, exits right away
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := run(ctx, *file, flag.Arg(0), opts); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// run looks up the domains from the file, from stdin when the argument is -, the ASN given after the asn subcommand,
+// or else the domain given as argument
+func run(ctx context.Context, file string, arg string, opts types.Options) error {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return domaininfo.RunBulkCommand(ctx, f, os.Stdout, opts)
+	}
+	if arg == "-" {
+		return domaininfo.RunBulkCommand(ctx, os.Stdin, os.Stdout, opts)
+	}
+	if arg == "asn" {
+		if flag.NArg() < 2 {
+			return fmt.Errorf("asn requires an ASN argument")
+		}
+		return domaininfo.RunASNCommand(ctx, flag.Arg(1), opts)
+	}
+	return domaininfo.RunCommand(ctx, arg, opts)
+}
+
+// defaultCacheDir returns the domaininfo directory in the user's cache directory, or an empty string when there is none
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "domaininfo")
+}
@@ -1,9 +1,10 @@
 module github.com/marc-barry/domaininfo
 
-go 1.15
+go 1.18
+
+require github.com/miekg/dns v1.1.35
 
 require (
-	github.com/miekg/dns v1.1.35
 	golang.org/x/crypto v0.0.0-20201208171446-5f87f3452ae9 // indirect
 	golang.org/x/net v0.0.0-20201207224615-747e23833adb // indirect
 	golang.org/x/sys v0.0.0-20201207223542-d4d67f95c62d // indirect
@@ -0,0 +1,91 @@
+// Package dnstest provides in-process DNS servers for tests
+package dnstest
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// Start starts a DNS server answering over UDP and TCP on the address, which may use port 0 to pick a free port.
+// The server is shut down when the test ends and the address it listens on is returned.
+func Start(t testing.TB, address string, handler dns.HandlerFunc) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		t.Skipf("listening on %s: %v", address, err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		t.Skipf("listening on %s: %v", address, err)
+	}
+
+	for _, s := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+		started := make(chan struct{})
+		s.NotifyStartedFunc = func() { close(started) }
+		go s.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { s.Shutdown() })
+	}
+	return pc.LocalAddr().String()
+}
+
+// Rcode returns a handler answering every query with the rcode
+func Rcode(rcode int) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+	}
+}
+
+// Zone returns a handler answering authoritatively from the records in zone file format.
+// Queries for names with records but none of the requested type get NODATA and other names get NXDOMAIN,
+// with the first SOA record of the zone in the authority section.
+func Zone(t testing.TB, zone string) dns.HandlerFunc {
+	t.Helper()
+
+	rrs := make([]dns.RR, 0)
+	zp := dns.NewZoneParser(strings.NewReader(zone), ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatalf("parsing zone: %v", err)
+	}
+
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		q := r.Question[0]
+
+		exists := false
+		var soa dns.RR
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == dns.TypeSOA && soa == nil {
+				soa = rr
+			}
+			if !strings.EqualFold(rr.Header().Name, q.Name) {
+				continue
+			}
+			exists = true
+			if rr.Header().Rrtype == q.Qtype || rr.Header().Rrtype == dns.TypeCNAME {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		if len(m.Answer) == 0 {
+			if !exists {
+				m.Rcode = dns.RcodeNameError
+			}
+			if soa != nil {
+				m.Ns = append(m.Ns, soa)
+			}
+		}
+		w.WriteMsg(m)
+	}
+}
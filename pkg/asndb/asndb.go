@@ -0,0 +1,92 @@
+package asndb
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marc-barry/domaininfo/pkg/ip"
+)
+
+// DB contains the prefixes originated by each ASN from an offline prefix to AS dataset
+type DB struct {
+	prefixes map[string][]netip.Prefix
+}
+
+// Load reads a prefix to AS dataset file
+func Load(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads a prefix to AS dataset in the CAIDA Routeviews pfx2as format, with one prefix per line:
+// the address, the prefix length and the origin ASNs, separated by whitespace.
+// Multiple origin ASNs are separated by _ and AS sets by commas. Blank lines and lines starting with # are skipped.
+func Parse(r io.Reader) (*DB, error) {
+	db := &DB{prefixes: make(map[string][]netip.Prefix)}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		f := strings.Fields(text)
+		if len(f) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 fields, got %d", line, len(f))
+		}
+		prefix, err := ip.ParsePrefix(f[0] + "/" + f[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		for _, asn := range strings.FieldsFunc(f[2], func(r rune) bool { return r == '_' || r == ',' }) {
+			db.prefixes[asn] = append(db.prefixes[asn], prefix)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, prefixes := range db.prefixes {
+		sort.Slice(prefixes, func(i, j int) bool { return lessPrefix(prefixes[i], prefixes[j]) })
+	}
+	return db, nil
+}
+
+// lessPrefix orders IPv4 prefixes before IPv6 prefixes, then by address and by length
+func lessPrefix(a netip.Prefix, b netip.Prefix) bool {
+	if c := a.Addr().Compare(b.Addr()); c != 0 {
+		return c < 0
+	}
+	return a.Bits() < b.Bits()
+}
+
+// Prefixes returns the prefixes originated by the ASN
+func (db *DB) Prefixes(asn string) []string {
+	prefixes := make([]string, 0)
+	for _, prefix := range db.prefixes[asn] {
+		prefixes = append(prefixes, prefix.String())
+	}
+	return prefixes
+}
+
+// NormalizeASN returns the number of an ASN given with or without the AS prefix
+func NormalizeASN(asn string) (string, error) {
+	n := strings.TrimPrefix(strings.ToUpper(asn), "AS")
+	if _, err := strconv.ParseUint(n, 10, 32); err != nil {
+		return "", fmt.Errorf("invalid ASN %s", asn)
+	}
+	return n, nil
+}
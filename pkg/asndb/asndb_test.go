@@ -0,0 +1,88 @@
+package asndb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	db, err := Parse(strings.NewReader(`# routeviews pfx2as
+2a04:4e42::	32	54113
+151.101.64.0	18	54113
+
+151.101.0.0	16	54113
+151.101.1.67	24	54113
+192.0.2.0	24	64500_64501
+198.51.100.0	24	64502,64503
+2001:db8::	32	64500
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		asn  string
+		want []string
+	}{
+		{"54113", []string{"151.101.0.0/16", "151.101.1.0/24", "151.101.64.0/18", "2a04:4e42::/32"}},
+		{"64500", []string{"192.0.2.0/24", "2001:db8::/32"}},
+		{"64501", []string{"192.0.2.0/24"}},
+		{"64502", []string{"198.51.100.0/24"}},
+		{"64503", []string{"198.51.100.0/24"}},
+		{"64510", []string{}},
+	}
+	for _, tt := range tests {
+		if got := db.Prefixes(tt.asn); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Prefixes(%s) = %v, want %v", tt.asn, got, tt.want)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"missing asn", "192.0.2.0\t24\n", "line 1: expected 3 fields, got 2"},
+		{"extra field", "# header\n192.0.2.0\t24\t64500\textra\n", "line 2: expected 3 fields, got 4"},
+		{"invalid address", "192.0.2\t24\t64500\n", "line 1: "},
+		{"invalid length", "192.0.2.0\t33\t64500\n", "line 1: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.in))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeASN(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"54113", "54113", false},
+		{"AS54113", "54113", false},
+		{"as54113", "54113", false},
+		{"4294967295", "4294967295", false},
+		{"4294967296", "", true},
+		{"x", "", true},
+		{"AS", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeASN(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeASN(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeASN(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// entry is the file contents of a cached value
+type entry struct {
+	Key    string          `json:"key"`
+	Stored time.Time       `json:"stored"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// Store is a file-backed cache keeping each value as a JSON file in a directory.
+// A nil Store doesn't cache anything.
+type Store struct {
+	dir     string
+	maxAge  time.Duration
+	refresh bool
+}
+
+// NewStore constructs a store in the directory, creating it if needed.
+// Values older than the max age are ignored, and when refreshing all stored values are ignored but still replaced.
+func NewStore(dir string, maxAge time.Duration, refresh bool) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, maxAge: maxAge, refresh: refresh}, nil
+}
+
+// path returns the file path of the value for a key
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get decodes the value stored for the key into v, reporting if a fresh value was found
+func (s *Store) Get(key string, v interface{}) bool {
+	if s == nil || s.refresh {
+		return false
+	}
+
+	b, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return false
+	}
+	var e entry
+	if err := json.Unmarshal(b, &e); err != nil || e.Key != key {
+		return false
+	}
+	if s.maxAge != 0 && time.Since(e.Stored) > s.maxAge {
+		return false
+	}
+	return json.Unmarshal(e.Value, v) == nil
+}
+
+// Put stores the value for the key. The file is written to a temporary file first so that readers never see a partial value.
+func (s *Store) Put(key string, v interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry{Key: key, Stored: time.Now(), Value: value})
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(s.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path(key))
+}
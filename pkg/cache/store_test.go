@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStore(t *testing.T) {
+	s, err := NewStore(t.TempDir(), time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v []string
+	if s.Get("asn/54113", &v) {
+		t.Fatalf("Get() found a value before Put()")
+	}
+	if err := s.Put("asn/54113", []string{"FASTLY, US"}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Get("asn/54113", &v) || len(v) != 1 || v[0] != "FASTLY, US" {
+		t.Errorf("Get() = %v, want [FASTLY, US]", v)
+	}
+
+	refreshing := &Store{dir: s.dir, maxAge: time.Hour, refresh: true}
+	if refreshing.Get("asn/54113", &v) {
+		t.Errorf("Get() found a value while refreshing")
+	}
+	expired := &Store{dir: s.dir, maxAge: time.Nanosecond}
+	time.Sleep(time.Millisecond)
+	if expired.Get("asn/54113", &v) {
+		t.Errorf("Get() found a value older than the max age")
+	}
+}
+
+func TestNilStore(t *testing.T) {
+	var s *Store
+	var v []string
+	if err := s.Put("asn/54113", []string{"FASTLY, US"}); err != nil {
+		t.Errorf("Put() error = %v", err)
+	}
+	if s.Get("asn/54113", &v) {
+		t.Errorf("Get() found a value in a nil store")
+	}
+}
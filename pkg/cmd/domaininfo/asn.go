@@ -0,0 +1,69 @@
+package domaininfo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/marc-barry/domaininfo/pkg/asndb"
+	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// RunASNCommand runs the asn command for an ASN given with or without the AS prefix.
+// The prefixes originated by the ASN are included when an offline dataset is given.
+func RunASNCommand(ctx context.Context, arg string, opts types.Options) error {
+	if err := validateOptions(opts); err != nil {
+		return err
+	}
+	asn, err := asndb.NormalizeASN(arg)
+	if err != nil {
+		return err
+	}
+
+	var db *asndb.DB
+	if opts.ASNDataset != "" {
+		db, err = asndb.Load(opts.ASNDataset)
+		if err != nil {
+			return fmt.Errorf("loading ASN dataset: %v", err)
+		}
+	}
+
+	resolver, err := newResolver(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Debug {
+		defer logHealth(resolver)
+	}
+	store := newStore(opts)
+
+	if opts.Deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+	ctx = dnsutil.WithMetadata(ctx)
+
+	errs := newSectionErrors()
+	descriptions, descriptionsErr := dnsutil.ASNDescriptions(ctx, resolver, store, []string{asn})
+	errs.record("asnDescriptions", descriptionsErr)
+
+	report := types.ASNReport{
+		ASN:             asn,
+		ASNDescriptions: descriptions,
+		Errors:          errs.errors,
+	}
+	if db != nil {
+		report.Prefixes = db.Prefixes(asn)
+	}
+	report.Metadata = dnsutil.MetadataFromContext(ctx)
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+
+	return errs.err()
+}
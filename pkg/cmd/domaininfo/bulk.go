@@ -0,0 +1,116 @@
+package domaininfo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// bulkFailure contains a domain for which all the requested sections failed
+type bulkFailure struct {
+	domain string
+	err    error
+}
+
+// RunBulkCommand runs the domaininfo command for each domain or IP address read from the reader, one per line.
+// Blank lines and lines starting with # are skipped. The info of each domain is written to the writer as a line of JSON,
+// progress is reported on stderr and the domains for which all the requested sections failed are summarized at the end.
+func RunBulkCommand(ctx context.Context, r io.Reader, w io.Writer, opts types.Options) error {
+	if err := validateOptions(opts); err != nil {
+		return err
+	}
+
+	resolver, err := newResolver(opts)
+	if err != nil {
+		return err
+	}
+	if opts.Debug {
+		defer logHealth(resolver)
+	}
+	store := newStore(opts)
+
+	if opts.Deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
+	}
+
+	workers := opts.Workers
+	if workers < 1 {
+		workers = types.DEFAULTWORKERS
+	}
+
+	domains := make(chan string)
+	var mu sync.Mutex
+	var done, partial int
+	failures := make([]bulkFailure, 0)
+	enc := json.NewEncoder(w)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range domains {
+				info, errs, err := lookup(ctx, resolver, store, domain, opts)
+
+				mu.Lock()
+				if encErr := enc.Encode(info); encErr != nil && err == nil {
+					err = encErr
+				}
+				done++
+				if err != nil {
+					failures = append(failures, bulkFailure{domain: domain, err: err})
+				} else if len(errs) != 0 {
+					partial++
+				}
+				fmt.Fprintf(os.Stderr, "\r%d domains done, %d failed, %d partial", done, len(failures), partial)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	scanErr := scanDomains(ctx, r, domains)
+	close(domains)
+	wg.Wait()
+	if done != 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if scanErr != nil {
+		return scanErr
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].domain < failures[j].domain })
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", f.domain, f.err)
+	}
+	return fmt.Errorf("%d of %d domains failed", len(failures), done)
+}
+
+// scanDomains sends each domain read from the reader to the channel until the reader is exhausted or the context is done
+func scanDomains(ctx context.Context, r io.Reader, domains chan<- string) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		domain := strings.TrimSpace(scanner.Text())
+		if domain == "" || strings.HasPrefix(domain, "#") {
+			continue
+		}
+		select {
+		case domains <- domain:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,104 @@
+package domaininfo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// bulkServer starts a DNS server answering the names in example.com from a zone and failing every other query
+func bulkServer(t *testing.T) string {
+	zone := dnstest.Zone(t, `
+example.com. 300 IN SOA ns1.example.com. host.example.com. 1 7200 3600 1209600 60
+example.com. 300 IN NS ns1.example.com.
+ns1.example.com. 300 IN A 127.0.0.1
+www.example.com. 300 IN A 192.0.2.1
+api.example.com. 300 IN A 192.0.2.2
+`)
+	return dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+		if !dns.IsSubDomain("example.com.", r.Question[0].Name) {
+			dnstest.Rcode(dns.RcodeServerFailure)(w, r)
+			return
+		}
+		zone(w, r)
+	})
+}
+
+func TestRunBulkCommand(t *testing.T) {
+	opts := types.Options{Resolvers: []string{bulkServer(t)}, Timeout: time.Second, Workers: 2, IPv4Only: true}
+	input := `# domains to look up
+www.example.com
+
+  api.example.com  
+# a.fail.test
+missing.example.com
+`
+	var out bytes.Buffer
+	if err := RunBulkCommand(context.Background(), strings.NewReader(input), &out, opts); err != nil {
+		t.Fatalf("RunBulkCommand() error = %v", err)
+	}
+
+	domains := make([]string, 0)
+	addresses := make(map[string][]string)
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var info types.DomainInfo
+		if err := json.Unmarshal(scanner.Bytes(), &info); err != nil {
+			t.Fatalf("line %q isn't a JSON object: %v", scanner.Text(), err)
+		}
+		domains = append(domains, info.Domain)
+		for address := range info.IPv4AddressInfo {
+			addresses[info.Domain] = append(addresses[info.Domain], address)
+		}
+	}
+	sort.Strings(domains)
+	if want := []string{"api.example.com", "missing.example.com", "www.example.com"}; !reflect.DeepEqual(domains, want) {
+		t.Errorf("domains written = %v, want %v", domains, want)
+	}
+	if want := map[string][]string{"www.example.com": {"192.0.2.1"}, "api.example.com": {"192.0.2.2"}}; !reflect.DeepEqual(addresses, want) {
+		t.Errorf("addresses = %v, want %v", addresses, want)
+	}
+}
+
+func TestRunBulkCommandFailures(t *testing.T) {
+	opts := types.Options{Resolvers: []string{bulkServer(t)}, Timeout: time.Second, Workers: 2}
+	input := "a.fail.test\nwww.example.com\nb.fail.test\n"
+
+	var out bytes.Buffer
+	err := RunBulkCommand(context.Background(), strings.NewReader(input), &out, opts)
+	if err == nil || err.Error() != "2 of 3 domains failed" {
+		t.Errorf("RunBulkCommand() error = %v, want 2 of 3 domains failed", err)
+	}
+
+	// The info of the failed domains is still written with their errors
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("%d lines written, want 3:\n%s", len(lines), out.String())
+	}
+	failed := 0
+	for _, line := range lines {
+		var info types.DomainInfo
+		if err := json.Unmarshal([]byte(line), &info); err != nil {
+			t.Fatalf("line %q isn't a JSON object: %v", line, err)
+		}
+		if strings.HasSuffix(info.Domain, ".fail.test") {
+			failed++
+			if len(info.Errors) == 0 {
+				t.Errorf("%s written without errors", info.Domain)
+			}
+		}
+	}
+	if failed != 2 {
+		t.Errorf("%d failed domains written, want 2", failed)
+	}
+}
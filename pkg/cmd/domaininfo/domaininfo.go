@@ -1,49 +1,363 @@
 package domaininfo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
 
+	"github.com/marc-barry/domaininfo/pkg/cache"
 	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/ip"
 	"github.com/marc-barry/domaininfo/pkg/types"
 )
 
-// RunCommand runs the domaininf command
-func RunCommand(domain string) error {
-	resolver := dnsutil.NewResolver("1.1.1.1:53")
-
-	targets, err := dnsutil.CNAMEChain(resolver, domain)
-	if err != nil {
+// RunCommand runs the domaininf command for a domain or an IP address, stopping the in-flight queries when the context is cancelled
+func RunCommand(ctx context.Context, arg string, opts types.Options) error {
+	if err := validateOptions(opts); err != nil {
 		return err
 	}
 
-	ipv4s, err := dnsutil.IPv4List(resolver, domain)
+	resolver, err := newResolver(opts)
 	if err != nil {
 		return err
 	}
-	ipv6s, err := dnsutil.IPv6List(resolver, domain)
-	if err != nil {
-		return err
+	if opts.Debug {
+		defer logHealth(resolver)
 	}
+	store := newStore(opts)
 
-	ipv4Info, ipv6Info, asns, err := dnsutil.AddressesInfos(resolver, ipv4s, ipv6s)
-	if err != nil {
-		return err
+	if opts.Deadline != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Deadline)
+		defer cancel()
 	}
 
-	b, err := json.MarshalIndent(
-		types.DomainInfo{
-			Domain:                domain,
-			CanonicalNamesTargets: targets,
-			IPv4AddressInfo:       ipv4Info,
-			IPv6AddressInfo:       ipv6Info,
-			ASNDescriptions:       dnsutil.ASNDescriptions(resolver, asns),
-			CAAInfos:              dnsutil.CAAInfos(resolver, domain, targets),
-		}, "", "  ")
+	info, _, infoErr := lookup(ctx, resolver, store, arg, opts)
+
+	b, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
 		return err
 	}
 	fmt.Println(string(b))
 
+	return infoErr
+}
+
+// validateOptions checks that the options are supported and consistent
+func validateOptions(opts types.Options) error {
+	if opts.IPv4Only && opts.IPv6Only {
+		return fmt.Errorf("ipv4-only and ipv6-only are mutually exclusive")
+	}
+	switch opts.Strategy {
+	case "", types.STRATEGYFAILOVER, types.STRATEGYROUNDROBIN, types.STRATEGYRACE:
+	default:
+		return fmt.Errorf("unsupported upstream strategy %s", opts.Strategy)
+	}
+	if opts.DoHMethod != "" && opts.DoHMethod != http.MethodGet && opts.DoHMethod != http.MethodPost {
+		return fmt.Errorf("unsupported DNS-over-HTTPS method %s", opts.DoHMethod)
+	}
+	if opts.Iterative && len(opts.Resolvers) != 0 {
+		return fmt.Errorf("iterative and resolver are mutually exclusive")
+	}
 	return nil
 }
+
+// lookup looks up the IP info when the argument is an IP address, the prefix info when it is a prefix and the domain info otherwise.
+// The info is returned with the errors of its sections and an error when all the requested sections failed.
+func lookup(ctx context.Context, resolver *dnsutil.Resolver, store *cache.Store, arg string, opts types.Options) (interface{}, map[string]string, error) {
+	if address := net.ParseIP(arg); address != nil {
+		info, err := collectIP(ctx, resolver, store, address)
+		return info, info.Errors, err
+	}
+	if prefix, err := ip.ParsePrefix(arg); err == nil {
+		info, err := collectPrefix(ctx, resolver, store, prefix, opts)
+		return info, info.Errors, err
+	}
+	info, err := collect(ctx, resolver, store, arg, opts)
+	return info, info.Errors, err
+}
+
+// collectIP looks up the IP info of an IP address, returning an error when all the requested sections failed
+func collectIP(ctx context.Context, resolver *dnsutil.Resolver, store *cache.Store, address net.IP) (types.IPInfo, error) {
+	ctx = dnsutil.WithMetadata(ctx)
+
+	outcomes := make(map[string]string)
+	errs := newSectionErrors()
+
+	ipv4s := make([]net.IP, 0)
+	ipv6s := make([]net.IP, 0)
+	if address.To4() != nil {
+		ipv4s = append(ipv4s, address)
+	} else {
+		ipv6s = append(ipv6s, address)
+	}
+
+	var ptrInfos map[string]types.PTRInfo
+	var ipv4Info, ipv6Info map[string][]types.ASNInfo
+	var asns []string
+	var asnDescriptions []types.ASNDescription
+	var ptrsErr, asnsErr, asnDescriptionsErr error
+	parallel(
+		func() { ptrInfos, ptrsErr = dnsutil.PTRInfos(ctx, resolver, []net.IP{address}) },
+		func() {
+			ipv4Info, ipv6Info, asns, asnsErr = dnsutil.AddressesInfos(ctx, resolver, store, ipv4s, ipv6s)
+			asnDescriptions, asnDescriptionsErr = dnsutil.ASNDescriptions(ctx, resolver, store, asns)
+		},
+	)
+
+	outcomes["PTR"] = ptrInfos[address.String()].Outcome
+	errs.record("ptr", ptrsErr)
+	errs.record("asn", asnsErr)
+	if len(asns) != 0 {
+		errs.record("asnDescriptions", asnDescriptionsErr)
+	}
+
+	asnInfo := ipv4Info[address.String()]
+	if asnInfo == nil {
+		asnInfo = ipv6Info[address.String()]
+	}
+
+	info := types.IPInfo{
+		IP:              address.String(),
+		ASNInfo:         asnInfo,
+		PTRInfo:         ptrInfos[address.String()],
+		ASNDescriptions: asnDescriptions,
+		QueryOutcomes:   outcomes,
+		Errors:          errs.errors,
+	}
+	info.Metadata = dnsutil.MetadataFromContext(ctx)
+
+	return info, errs.err()
+}
+
+// collect looks up the domain info of a domain, returning an error when all the requested sections failed
+func collect(ctx context.Context, resolver *dnsutil.Resolver, store *cache.Store, domain string, opts types.Options) (types.DomainInfo, error) {
+	ctx = dnsutil.WithMetadata(ctx)
+	domain = dnsutil.SearchName(ctx, resolver, domain)
+
+	outcomes := make(map[string]string)
+	errs := newSectionErrors()
+
+	// The CNAME, A and AAAA lookups are independent of each other
+	var targets []string
+	var targetsErr, ipv4sErr, ipv6sErr error
+	ipv4s := make([]net.IP, 0)
+	ipv6s := make([]net.IP, 0)
+	// The referrals followed by an iterative resolver are traced for the first address lookup
+	traceCtx := dnsutil.WithTrace(ctx)
+	ipv4Ctx, ipv6Ctx := traceCtx, ctx
+	if opts.IPv6Only {
+		ipv4Ctx, ipv6Ctx = ctx, traceCtx
+	}
+	parallel(
+		func() { targets, targetsErr = dnsutil.CNAMEChain(ctx, resolver, domain) },
+		func() {
+			if !opts.IPv6Only {
+				ipv4s, ipv4sErr = dnsutil.IPv4List(ipv4Ctx, resolver, domain)
+			}
+		},
+		func() {
+			if !opts.IPv4Only {
+				ipv6s, ipv6sErr = dnsutil.IPv6List(ipv6Ctx, resolver, domain)
+			}
+		},
+	)
+
+	outcomes["CNAME"] = dnsutil.Outcome(targetsErr)
+	if targetsErr == nil && len(targets) == 0 {
+		outcomes["CNAME"] = dnsutil.Outcome(dnsutil.ErrNoData)
+	}
+	errs.record("cname", targetsErr)
+	if !opts.IPv6Only {
+		outcomes["A"] = dnsutil.Outcome(ipv4sErr)
+		errs.record("a", ipv4sErr)
+	}
+	if !opts.IPv4Only {
+		outcomes["AAAA"] = dnsutil.Outcome(ipv6sErr)
+		errs.record("aaaa", ipv6sErr)
+	}
+
+	// The ASN and PTR lookups depend on the addresses while the MX, NS and CAA lookups only depend on the domain and the CNAME targets
+	var ipv4Info, ipv6Info map[string][]types.ASNInfo
+	var ptrInfos map[string]types.PTRInfo
+	var mxInfos []types.MXInfo
+	var nsInfo types.NSInfo
+	var asns, mxASNs []string
+	var caaInfos []types.CAAInfo
+	var asnsErr, ptrsErr, mxErr, nsErr, caaInfosErr error
+	parallel(
+		func() { ipv4Info, ipv6Info, asns, asnsErr = dnsutil.AddressesInfos(ctx, resolver, store, ipv4s, ipv6s) },
+		func() {
+			ptrInfos, ptrsErr = dnsutil.PTRInfos(ctx, resolver, append(append([]net.IP{}, ipv4s...), ipv6s...))
+		},
+		func() { mxInfos, mxASNs, mxErr = dnsutil.MXInfos(ctx, resolver, store, domain) },
+		func() { nsInfo, nsErr = dnsutil.NSInfos(ctx, resolver, store, domain) },
+		func() { caaInfos, caaInfosErr = dnsutil.CAAInfos(ctx, resolver, domain, targets) },
+	)
+
+	// The ASN descriptions cover the ASNs of the addresses, the mail exchangers and the nameservers,
+	// while the SOA and delegation NS records are queried from the nameservers directly
+	asns = mergeASNs(asns, mxASNs, nsInfo.ASNs)
+	var asnDescriptions []types.ASNDescription
+	var soaReport *types.SOAReport
+	var delegationReport *types.DelegationReport
+	var asnDescriptionsErr, soaErr, delegationErr error
+	parallel(
+		func() { asnDescriptions, asnDescriptionsErr = dnsutil.ASNDescriptions(ctx, resolver, store, asns) },
+		func() {
+			if opts.CheckSOA && nsErr == nil {
+				report, err := dnsutil.SOAReport(ctx, resolver, nsInfo)
+				soaReport, soaErr = &report, err
+			}
+		},
+		func() {
+			if opts.CheckDelegation && nsErr == nil {
+				report, err := dnsutil.DelegationReport(ctx, resolver, nsInfo)
+				delegationReport, delegationErr = &report, err
+			}
+		},
+	)
+
+	if len(ipv4s)+len(ipv6s) != 0 {
+		errs.record("asn", asnsErr)
+		errs.record("ptr", ptrsErr)
+	}
+	outcomes["MX"] = dnsutil.Outcome(mxErr)
+	errs.record("mx", mxErr)
+	outcomes["NS"] = dnsutil.Outcome(nsErr)
+	errs.record("ns", nsErr)
+	if soaReport != nil {
+		errs.record("soa", soaErr)
+	}
+	if delegationReport != nil {
+		errs.record("delegation", delegationErr)
+	}
+	if len(asns) != 0 {
+		errs.record("asnDescriptions", asnDescriptionsErr)
+	}
+	errs.record("caa", caaInfosErr)
+
+	info := types.DomainInfo{
+		Domain:                domain,
+		CanonicalNamesTargets: targets,
+		IPv4AddressInfo:       ipv4Info,
+		IPv6AddressInfo:       ipv6Info,
+		PTRInfo:               ptrInfos,
+		MXInfos:               mxInfos,
+		NSInfo:                nsInfo,
+		SOAReport:             soaReport,
+		DelegationReport:      delegationReport,
+		Trace:                 dnsutil.TraceFromContext(traceCtx),
+		ASNDescriptions:       asnDescriptions,
+		CAAInfos:              caaInfos,
+		QueryOutcomes:         outcomes,
+		Errors:                errs.errors,
+	}
+	info.Metadata = dnsutil.MetadataFromContext(ctx)
+
+	return info, errs.err()
+}
+
+// mergeASNs returns the sorted union of the lists of ASNs
+func mergeASNs(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	asns := make([]string, 0)
+	for _, list := range lists {
+		for _, asn := range list {
+			if !seen[asn] {
+				seen[asn] = true
+				asns = append(asns, asn)
+			}
+		}
+	}
+	sort.Strings(asns)
+	return asns
+}
+
+// parallel calls each function in its own goroutine and waits for all of them to return
+func parallel(fns ...func()) {
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+	wg.Wait()
+}
+
+// sectionErrors collects the errors of the sections of a run
+type sectionErrors struct {
+	errors    map[string]string
+	requested int
+}
+
+// newSectionErrors constructs an empty collection of section errors
+func newSectionErrors() *sectionErrors {
+	return &sectionErrors{errors: make(map[string]string)}
+}
+
+// record records the outcome of a requested section which had something to look up.
+// Answers such as NXDOMAIN or NODATA aren't errors.
+func (e *sectionErrors) record(section string, err error) {
+	e.requested++
+	if err != nil && !dnsutil.IsAnswerError(err) {
+		e.errors[section] = err.Error()
+	}
+}
+
+// err returns an error when all the requested sections failed
+func (e *sectionErrors) err() error {
+	if e.requested != 0 && len(e.errors) == e.requested {
+		return fmt.Errorf("all %d requested sections failed", e.requested)
+	}
+	return nil
+}
+
+// newResolver constructs the resolver from the options, falling back to the system configuration when no resolver is given.
+// Iterative resolvers resolve names themselves from the root servers.
+func newResolver(opts types.Options) (*dnsutil.Resolver, error) {
+	if opts.Iterative {
+		return dnsutil.NewIterativeResolver(opts)
+	}
+	if len(opts.Resolvers) != 0 {
+		return dnsutil.NewResolverWithOptions(opts), nil
+	}
+
+	resolver, err := dnsutil.NewResolverFromConfig(opts.ResolvConf, opts)
+	if err != nil {
+		if opts.ResolvConf != types.DEFAULTRESOLVCONF {
+			return nil, err
+		}
+		opts.Resolvers = []string{types.DEFAULTRESOLVER}
+		return dnsutil.NewResolverWithOptions(opts), nil
+	}
+	return resolver, nil
+}
+
+// newStore constructs the store caching ASN lookups, or nil when caching them is disabled.
+// The ASN lookups aren't cached when the cache directory can't be created, such as on a read-only home directory.
+func newStore(opts types.Options) *cache.Store {
+	if opts.NoCache || opts.CacheDir == "" {
+		return nil
+	}
+	store, err := cache.NewStore(opts.CacheDir, opts.CacheMaxAge, opts.RefreshCache)
+	if err != nil {
+		log.Printf("not caching ASN lookups: %v", err)
+		return nil
+	}
+	return store
+}
+
+// logHealth logs the health of each upstream resolver
+func logHealth(resolver *dnsutil.Resolver) {
+	for _, h := range resolver.Health() {
+		log.Printf("upstream %s: %d successes, %d failures, healthy %t %s", h.Address, h.Successes, h.Failures, h.Healthy, h.LastError)
+	}
+}
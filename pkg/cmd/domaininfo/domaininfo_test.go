@@ -0,0 +1,80 @@
+package domaininfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestCollectAllServFail(t *testing.T) {
+	address := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	opts := types.Options{Resolvers: []string{address}, Timeout: time.Second}
+	resolver := dnsutil.NewResolverWithOptions(opts)
+
+	info, err := collect(context.Background(), resolver, nil, "example.com", opts)
+	if err == nil {
+		t.Fatalf("collect() error = nil, want an error when all sections fail")
+	}
+	for _, section := range []string{"cname", "a", "aaaa", "mx", "ns", "caa"} {
+		if _, ok := info.Errors[section]; !ok {
+			t.Errorf("errors[%q] missing, got %v", section, info.Errors)
+		}
+	}
+	for _, query := range []string{"CNAME", "A", "AAAA", "MX"} {
+		if got := info.QueryOutcomes[query]; got != "SERVFAIL" {
+			t.Errorf("queryOutcomes[%q] = %q, want SERVFAIL", query, got)
+		}
+	}
+}
+
+func TestCollectAllRefused(t *testing.T) {
+	address := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeRefused))
+	opts := types.Options{Resolvers: []string{address}, Timeout: time.Second}
+	resolver := dnsutil.NewResolverWithOptions(opts)
+
+	if _, err := collect(context.Background(), resolver, nil, "example.com", opts); err == nil {
+		t.Fatalf("collect() error = nil, want an error when all sections are refused")
+	}
+}
+
+func TestCollectNXDomain(t *testing.T) {
+	address := dnstest.Start(t, "127.0.0.1:0", dnstest.Zone(t, `
+example.com. 300 IN SOA ns1.example.com. host.example.com. 1 7200 3600 1209600 60
+example.com. 300 IN NS ns1.example.com.
+ns1.example.com. 300 IN A 127.0.0.1
+`))
+	opts := types.Options{Resolvers: []string{address}, Timeout: time.Second}
+	resolver := dnsutil.NewResolverWithOptions(opts)
+
+	info, err := collect(context.Background(), resolver, nil, "nope.example.com", opts)
+	if err != nil {
+		t.Fatalf("collect() error = %v, want nil since NXDOMAIN is an answer", err)
+	}
+	if len(info.Errors) != 0 {
+		t.Errorf("errors = %v, want none", info.Errors)
+	}
+	if got := info.QueryOutcomes["A"]; got != "NXDOMAIN" {
+		t.Errorf("queryOutcomes[A] = %q, want NXDOMAIN", got)
+	}
+}
+
+func TestNewStoreUnusableDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if store := newStore(types.Options{CacheDir: filepath.Join(file, "domaininfo")}); store != nil {
+		t.Errorf("newStore() = %v, want nil when the cache directory can't be created", store)
+	}
+	if store := newStore(types.Options{CacheDir: t.TempDir()}); store == nil {
+		t.Errorf("newStore() = nil, want a store")
+	}
+}
@@ -0,0 +1,99 @@
+package domaininfo
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+
+	"github.com/marc-barry/domaininfo/pkg/cache"
+	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/ip"
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// collectPrefix looks up the origin ASNs of the addresses of a prefix, returning an error when all the requested sections failed.
+// Prefixes with more addresses than the maximum are split into blocks and the first address of each block is looked up instead.
+// The blocks are aggregated back into the minimal list of prefixes originated by each ASN,
+// the blocks without an origin ASN and the blocks whose lookup failed.
+func collectPrefix(ctx context.Context, resolver *dnsutil.Resolver, store *cache.Store, prefix netip.Prefix, opts types.Options) (types.PrefixInfo, error) {
+	ctx = dnsutil.WithMetadata(ctx)
+
+	errs := newSectionErrors()
+
+	max := opts.MaxAddresses
+	if max < 1 {
+		max = types.DEFAULTMAXADDRESSES
+	}
+	blocks := ip.Blocks(prefix, max)
+
+	addresses := make([]net.IP, 0, len(blocks))
+	for _, block := range blocks {
+		addresses = append(addresses, net.IP(block.Addr().AsSlice()))
+	}
+	infos, lookupErrs := dnsutil.AddressesOrigins(ctx, resolver, store, addresses)
+
+	// Blocks whose lookup failed are neither announced nor unannounced as far as we know
+	addressInfo := make(map[string][]types.ASNInfo)
+	asnBlocks := make(map[string][]netip.Prefix)
+	unannounced := make([]netip.Prefix, 0)
+	failed := make([]netip.Prefix, 0)
+	var asnsErr error
+	for i, block := range blocks {
+		if lookupErrs[i] != nil {
+			failed = append(failed, block)
+			if asnsErr == nil {
+				asnsErr = lookupErrs[i]
+			}
+			continue
+		}
+		addressInfo[block.Addr().String()] = infos[i]
+		if len(infos[i]) == 0 {
+			unannounced = append(unannounced, block)
+			continue
+		}
+		for _, info := range infos[i] {
+			for _, asn := range dnsutil.OriginASNs(info) {
+				asnBlocks[asn] = append(asnBlocks[asn], block)
+			}
+		}
+	}
+
+	asns := make([]string, 0, len(asnBlocks))
+	asnPrefixes := make(map[string][]string)
+	for asn, blocks := range asnBlocks {
+		asns = append(asns, asn)
+		asnPrefixes[asn] = prefixStrings(ip.Summarize(blocks))
+	}
+	sort.Strings(asns)
+
+	asnDescriptions, asnDescriptionsErr := dnsutil.ASNDescriptions(ctx, resolver, store, asns)
+	errs.record("asn", asnsErr)
+	if len(asns) != 0 {
+		errs.record("asnDescriptions", asnDescriptionsErr)
+	}
+
+	info := types.PrefixInfo{
+		Prefix:          prefix.String(),
+		Sampled:         blocks[0].Bits() != prefix.Addr().BitLen(),
+		BlockLength:     blocks[0].Bits(),
+		AddressInfo:     addressInfo,
+		ASNPrefixes:     asnPrefixes,
+		Unannounced:     prefixStrings(ip.Summarize(unannounced)),
+		Failed:          prefixStrings(ip.Summarize(failed)),
+		ASNDescriptions: asnDescriptions,
+		Errors:          errs.errors,
+	}
+	info.Metadata = dnsutil.MetadataFromContext(ctx)
+
+	return info, errs.err()
+}
+
+// prefixStrings returns the prefixes in CIDR notation
+func prefixStrings(prefixes []netip.Prefix) []string {
+	strs := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		strs = append(strs, p.String())
+	}
+	return strs
+}
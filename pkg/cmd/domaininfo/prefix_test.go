@@ -0,0 +1,69 @@
+package domaininfo
+
+import (
+	"context"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/dnsutil"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestCollectPrefix(t *testing.T) {
+	zone := dnstest.Zone(t, `
+0.0.0.10.origin.asn.cymru.com. 300 IN TXT "64500 | 10.0.0.0/31 | US | arin | 2016-02-01"
+1.0.0.10.origin.asn.cymru.com. 300 IN TXT "64500 | 10.0.0.0/31 | US | arin | 2016-02-01"
+AS64500.asn.cymru.com. 300 IN TXT "64500 | US | arin | 2011-10-04 | EXAMPLE, US"
+`)
+	// 10.0.0.2 isn't announced and the lookup of 10.0.0.3 fails
+	address := dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Name == "3.0.0.10.origin.asn.cymru.com." {
+			dnstest.Rcode(dns.RcodeServerFailure)(w, r)
+			return
+		}
+		zone(w, r)
+	})
+	opts := types.Options{Resolvers: []string{address}, Timeout: time.Second}
+	resolver := dnsutil.NewResolverWithOptions(opts)
+
+	info, err := collectPrefix(context.Background(), resolver, nil, netip.MustParsePrefix("10.0.0.0/30"), opts)
+	if err != nil {
+		t.Fatalf("collectPrefix() error = %v", err)
+	}
+	if want := map[string][]string{"64500": {"10.0.0.0/31"}}; !reflect.DeepEqual(info.ASNPrefixes, want) {
+		t.Errorf("asnPrefixes = %v, want %v", info.ASNPrefixes, want)
+	}
+	if want := []string{"10.0.0.2/32"}; !reflect.DeepEqual(info.Unannounced, want) {
+		t.Errorf("unannounced = %v, want %v", info.Unannounced, want)
+	}
+	if want := []string{"10.0.0.3/32"}; !reflect.DeepEqual(info.Failed, want) {
+		t.Errorf("failed = %v, want %v", info.Failed, want)
+	}
+	if _, ok := info.Errors["asn"]; !ok {
+		t.Errorf("errors = %v, want an asn error", info.Errors)
+	}
+	if len(info.ASNDescriptions) != 1 || info.ASNDescriptions[0].Org != "EXAMPLE, US" {
+		t.Errorf("asnDescriptions = %v, want EXAMPLE, US", info.ASNDescriptions)
+	}
+}
+
+func TestCollectPrefixAllServFail(t *testing.T) {
+	address := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	opts := types.Options{Resolvers: []string{address}, Timeout: time.Second}
+	resolver := dnsutil.NewResolverWithOptions(opts)
+
+	info, err := collectPrefix(context.Background(), resolver, nil, netip.MustParsePrefix("10.0.0.0/30"), opts)
+	if err == nil {
+		t.Fatalf("collectPrefix() error = nil, want an error when all lookups fail")
+	}
+	if len(info.Unannounced) != 0 {
+		t.Errorf("unannounced = %v, want none", info.Unannounced)
+	}
+	if want := []string{"10.0.0.0/30"}; !reflect.DeepEqual(info.Failed, want) {
+		t.Errorf("failed = %v, want %v", info.Failed, want)
+	}
+}
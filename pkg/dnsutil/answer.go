@@ -0,0 +1,93 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// Answer contains the full response to a query
+type Answer struct {
+	Name          string
+	Qtype         uint16
+	Rcode         int
+	Authoritative bool
+	Authenticated bool
+	Answer        []dns.RR
+	Authority     []dns.RR
+	Additional    []dns.RR
+}
+
+// newAnswer constructs an answer from the response to a question
+func newAnswer(q dns.Question, rsp *dns.Msg) *Answer {
+	return &Answer{
+		Name:          q.Name,
+		Qtype:         q.Qtype,
+		Rcode:         rsp.Rcode,
+		Authoritative: rsp.Authoritative,
+		Authenticated: rsp.AuthenticatedData,
+		Answer:        rsp.Answer,
+		Authority:     rsp.Ns,
+		Additional:    withoutOPT(rsp.Extra),
+	}
+}
+
+// withoutOPT removes the EDNS0 pseudo record from the additional section
+func withoutOPT(rrs []dns.RR) []dns.RR {
+	out := make([]dns.RR, 0, len(rrs))
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			out = append(out, rr)
+		}
+	}
+	return out
+}
+
+// Err returns an *RcodeError when the rcode of the answer isn't a success,
+// or an error matching ErrNoData when the answer section has no records of the requested type
+func (a *Answer) Err() error {
+	if a.Rcode != dns.RcodeSuccess {
+		return &RcodeError{Name: a.Name, Qtype: a.Qtype, Rcode: a.Rcode}
+	}
+	for _, rr := range a.Answer {
+		if rr.Header().Rrtype == a.Qtype {
+			return nil
+		}
+	}
+	return fmt.Errorf("lookup %s %s: %w", a.Name, dns.TypeToString[a.Qtype], ErrNoData)
+}
+
+// MinTTL returns the lowest TTL of the records in the answer section, or zero when there are none
+func (a *Answer) MinTTL() uint32 {
+	var ttl uint32
+	for i, rr := range a.Answer {
+		if i == 0 || rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return ttl
+}
+
+// Records returns the records of type T from a section of an answer
+func Records[T dns.RR](rrs []dns.RR) []T {
+	var out []T
+	for _, rr := range rrs {
+		if t, ok := rr.(T); ok {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// LookupRecords looks up records for a domain and returns those of type T from the answer section
+func LookupRecords[T dns.RR](ctx context.Context, r *Resolver, name string, qtype uint16) ([]T, error) {
+	a, err := r.Lookup(ctx, name, qtype)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Err(); err != nil {
+		return nil, err
+	}
+	return Records[T](a.Answer), nil
+}
@@ -0,0 +1,105 @@
+package dnsutil
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// MAXCACHEENTRIES contains the number of answers kept in the cache before entries are evicted, expired ones first
+const MAXCACHEENTRIES = 10000
+
+// cacheKey identifies a cached answer
+type cacheKey struct {
+	name  string
+	qtype uint16
+}
+
+// cacheEntry contains a cached answer and when it expires
+type cacheEntry struct {
+	answer  *Answer
+	expires time.Time
+}
+
+// answerCache is an in-memory cache of answers honoring the record TTLs
+type answerCache struct {
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// newAnswerCache constructs an empty cache
+func newAnswerCache() *answerCache {
+	return &answerCache{entries: make(map[cacheKey]cacheEntry)}
+}
+
+// get returns the cached answer for a query if it hasn't expired
+func (c *answerCache) get(name string, qtype uint16) (*Answer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := cacheKey{name: strings.ToLower(dns.Fqdn(name)), qtype: qtype}
+	e, ok := c.entries[key]
+	if ok && time.Now().Before(e.expires) {
+		return e.answer, true
+	}
+	if ok {
+		delete(c.entries, key)
+	}
+	return nil, false
+}
+
+// put caches an answer for its TTL. Server failures and answers with a zero TTL aren't cached.
+func (c *answerCache) put(a *Answer) {
+	ttl, ok := cacheTTL(a)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= MAXCACHEENTRIES {
+		c.evict(now)
+	}
+	key := cacheKey{name: strings.ToLower(a.Name), qtype: a.Qtype}
+	c.entries[key] = cacheEntry{answer: a, expires: now.Add(time.Duration(ttl) * time.Second)}
+}
+
+// evict makes room for an entry in a full cache by removing the expired entries,
+// or arbitrary entries when none have expired
+func (c *answerCache) evict(now time.Time) {
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	for k := range c.entries {
+		if len(c.entries) < MAXCACHEENTRIES {
+			return
+		}
+		delete(c.entries, k)
+	}
+}
+
+// cacheTTL returns how long an answer can be cached. Positive answers use the lowest record TTL while
+// NXDOMAIN and NODATA answers use the lower of the SOA TTL and minimum field from the authority section (RFC 2308).
+func cacheTTL(a *Answer) (uint32, bool) {
+	err := a.Err()
+	if err == nil {
+		return a.MinTTL(), true
+	}
+	if a.Rcode != dns.RcodeSuccess && a.Rcode != dns.RcodeNameError {
+		return 0, false
+	}
+
+	for _, soa := range Records[*dns.SOA](a.Authority) {
+		if soa.Minttl < soa.Hdr.Ttl {
+			return soa.Minttl, true
+		}
+		return soa.Hdr.Ttl, true
+	}
+	return 0, false
+}
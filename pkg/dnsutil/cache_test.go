@@ -0,0 +1,122 @@
+package dnsutil
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// mustRR parses a record in zone file format
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestCacheTTL(t *testing.T) {
+	soa := "example.com. 3600 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300"
+	tests := []struct {
+		name   string
+		answer *Answer
+		want   uint32
+		wantOK bool
+	}{
+		{"lowest record ttl", &Answer{Name: "www.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{
+			mustRR(t, "www.example.com. 300 IN CNAME web.example.com."),
+			mustRR(t, "web.example.com. 60 IN A 192.0.2.1"),
+			mustRR(t, "web.example.com. 120 IN A 192.0.2.2"),
+		}}, 60, true},
+		{"zero ttl", &Answer{Name: "www.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{
+			mustRR(t, "www.example.com. 0 IN A 192.0.2.1"),
+		}}, 0, true},
+		{"nxdomain soa minimum", &Answer{Name: "missing.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError, Authority: []dns.RR{
+			mustRR(t, soa),
+		}}, 300, true},
+		{"nodata soa minimum", &Answer{Name: "example.com.", Qtype: dns.TypeAAAA, Authority: []dns.RR{
+			mustRR(t, soa),
+		}}, 300, true},
+		{"nodata soa ttl lower than minimum", &Answer{Name: "example.com.", Qtype: dns.TypeAAAA, Authority: []dns.RR{
+			mustRR(t, "example.com. 30 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300"),
+		}}, 30, true},
+		{"nxdomain without soa", &Answer{Name: "missing.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError}, 0, false},
+		{"servfail", &Answer{Name: "www.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure, Authority: []dns.RR{
+			mustRR(t, soa),
+		}}, 0, false},
+		{"refused", &Answer{Name: "www.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeRefused}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := cacheTTL(tt.answer)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("cacheTTL() = %d, %t, want %d, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestAnswerCache(t *testing.T) {
+	c := newAnswerCache()
+	a := &Answer{Name: "WWW.Example.com.", Qtype: dns.TypeA, Answer: []dns.RR{mustRR(t, "www.example.com. 60 IN A 192.0.2.1")}}
+	c.put(a)
+	if got, ok := c.get("www.example.com", dns.TypeA); !ok || got != a {
+		t.Errorf("get() = %v, %t, want the cached answer", got, ok)
+	}
+	if _, ok := c.get("www.example.com.", dns.TypeAAAA); ok {
+		t.Errorf("get() of another type found an answer")
+	}
+
+	// Expire the entry rather than waiting for its TTL
+	key := cacheKey{name: "www.example.com.", qtype: dns.TypeA}
+	c.entries[key] = cacheEntry{answer: a, expires: time.Now().Add(-time.Second)}
+	if _, ok := c.get("www.example.com.", dns.TypeA); ok {
+		t.Errorf("get() found an expired answer")
+	}
+	if _, ok := c.entries[key]; ok {
+		t.Errorf("expired entry wasn't removed")
+	}
+
+	c.put(&Answer{Name: "www.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{mustRR(t, "www.example.com. 0 IN A 192.0.2.1")}})
+	c.put(&Answer{Name: "www.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure})
+	if len(c.entries) != 0 {
+		t.Errorf("answers with a zero TTL or a server failure were cached")
+	}
+}
+
+func TestAnswerCacheEviction(t *testing.T) {
+	c := newAnswerCache()
+	now := time.Now()
+	for i := 0; i < MAXCACHEENTRIES; i++ {
+		expires := now.Add(time.Hour)
+		if i%2 == 0 {
+			expires = now.Add(-time.Second)
+		}
+		c.entries[cacheKey{name: strconv.Itoa(i) + ".example.com.", qtype: dns.TypeA}] = cacheEntry{expires: expires}
+	}
+
+	c.put(&Answer{Name: "new.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{mustRR(t, "new.example.com. 60 IN A 192.0.2.1")}})
+	if want := MAXCACHEENTRIES/2 + 1; len(c.entries) != want {
+		t.Errorf("cache has %d entries after evicting, want %d", len(c.entries), want)
+	}
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			t.Errorf("expired entry %v wasn't evicted", k)
+		}
+	}
+
+	// When nothing has expired arbitrary entries are evicted to stay within the limit
+	for i := 0; len(c.entries) < MAXCACHEENTRIES; i++ {
+		c.entries[cacheKey{name: strconv.Itoa(i) + ".example.net.", qtype: dns.TypeA}] = cacheEntry{expires: now.Add(time.Hour)}
+	}
+	c.put(&Answer{Name: "other.example.com.", Qtype: dns.TypeA, Answer: []dns.RR{mustRR(t, "other.example.com. 60 IN A 192.0.2.1")}})
+	if len(c.entries) != MAXCACHEENTRIES {
+		t.Errorf("cache has %d entries, want %d", len(c.entries), MAXCACHEENTRIES)
+	}
+	if _, ok := c.get("other.example.com.", dns.TypeA); !ok {
+		t.Errorf("new answer wasn't cached")
+	}
+}
@@ -0,0 +1,205 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// DelegationReport checks the delegation of the zone of the NS info. The parent zone's servers are queried directly
+// for the delegation NS records and each nameserver of either the parent or the child zone is queried directly for
+// the NS records of its zone. Nameservers that aren't authoritative, refuse or don't answer are reported as lame,
+// and the nameservers only listed by either the parent or the child zone are reported as a mismatch.
+// An error is returned when the delegation couldn't be found.
+func DelegationReport(ctx context.Context, resolver *Resolver, nsInfo types.NSInfo) (types.DelegationReport, error) {
+	report := types.DelegationReport{
+		Zone:              nsInfo.Zone,
+		ParentNameservers: make([]string, 0),
+		ChildNameservers:  make([]string, 0),
+		ParentOnly:        make([]string, 0),
+		ChildOnly:         make([]string, 0),
+		Servers:           make([]types.DelegationServer, 0),
+		Lame:              make([]string, 0),
+	}
+	if nsInfo.Zone == "" || nsInfo.Zone == "." {
+		return report, fmt.Errorf("the zone %q has no parent zone", nsInfo.Zone)
+	}
+
+	i, _ := dns.NextLabel(nsInfo.Zone, 0)
+	parentZone, err := ZoneOf(ctx, resolver, nsInfo.Zone[i:])
+	if err != nil {
+		return report, err
+	}
+	report.ParentZone = parentZone
+
+	res, err := resolver.LookupNS(ctx, parentZone)
+	if err != nil {
+		return report, err
+	}
+	parentHosts := make([]string, 0)
+	for _, r := range res {
+		parentHosts = append(parentHosts, strings.ToLower(r.Ns))
+	}
+	sort.Strings(parentHosts)
+	parentAddresses, err := resolveNameservers(ctx, resolver, parentHosts)
+	if len(parentAddresses) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no addresses found for the nameservers of %s", parentZone)
+		}
+		return report, err
+	}
+
+	// The parent zone's servers should agree on the delegation so the first one answering is used
+	var parentErr error
+	for _, ns := range parentAddresses {
+		a, err := resolver.LookupFrom(ctx, ns.address, nsInfo.Zone, dns.TypeNS)
+		if err == nil && a.Rcode != dns.RcodeSuccess {
+			err = &RcodeError{Name: a.Name, Qtype: a.Qtype, Rcode: a.Rcode}
+		}
+		if err != nil {
+			if parentErr == nil {
+				parentErr = fmt.Errorf("%s (%s): %w", ns.name, ns.address, err)
+			}
+			continue
+		}
+		if hosts := zoneNameservers(a, nsInfo.Zone); len(hosts) != 0 {
+			report.ParentServer = fmt.Sprintf("%s (%s)", ns.name, ns.address)
+			report.ParentNameservers = hosts
+			break
+		}
+	}
+	if report.ParentServer == "" {
+		if parentErr == nil {
+			parentErr = fmt.Errorf("no server of %s answered with the delegation of %s", parentZone, nsInfo.Zone)
+		}
+		return report, parentErr
+	}
+
+	childHosts := append([]string{}, report.ParentNameservers...)
+	for _, ns := range nsInfo.Nameservers {
+		childHosts = append(childHosts, ns.Name)
+	}
+	childAddresses, _ := resolveNameservers(ctx, resolver, uniqueNames(childHosts))
+
+	servers := make([]types.DelegationServer, len(childAddresses))
+	forEach(len(childAddresses), resolver.concurrency, func(i int) {
+		servers[i] = delegationServer(ctx, resolver, nsInfo.Zone, childAddresses[i])
+	})
+
+	childHosts = make([]string, 0)
+	for _, server := range servers {
+		report.Servers = append(report.Servers, server)
+		if server.Status != types.DELEGATIONOK {
+			report.Lame = append(report.Lame, fmt.Sprintf("%s (%s)", server.Nameserver, server.Address))
+			continue
+		}
+		childHosts = append(childHosts, server.Nameservers...)
+	}
+	report.ChildNameservers = uniqueNames(childHosts)
+
+	report.ParentOnly = difference(report.ParentNameservers, report.ChildNameservers)
+	report.ChildOnly = difference(report.ChildNameservers, report.ParentNameservers)
+	report.Mismatch = len(report.ParentOnly)+len(report.ChildOnly) != 0
+
+	return report, nil
+}
+
+// resolveNameservers returns the addresses of each nameserver, IPv4 addresses first, in the order of the nameservers
+func resolveNameservers(ctx context.Context, resolver *Resolver, hosts []string) ([]nameserverAddress, error) {
+	ipv4s, ipv6s, err := hostAddresses(ctx, resolver, hosts)
+
+	addresses := make([]nameserverAddress, 0)
+	for i, host := range hosts {
+		for _, address := range append(append([]net.IP{}, ipv4s[i]...), ipv6s[i]...) {
+			addresses = append(addresses, nameserverAddress{name: host, address: address.String()})
+		}
+	}
+	return addresses, err
+}
+
+// delegationServer queries an address of a nameserver for the NS records of its zone and returns its status
+func delegationServer(ctx context.Context, resolver *Resolver, zone string, ns nameserverAddress) types.DelegationServer {
+	server := types.DelegationServer{Nameserver: ns.name, Address: ns.address, Nameservers: make([]string, 0)}
+
+	a, err := resolver.LookupFrom(ctx, ns.address, zone, dns.TypeNS)
+	switch {
+	case err != nil && isTimeout(err):
+		server.Status = types.DELEGATIONTIMEOUT
+		server.Error = err.Error()
+	case err != nil:
+		server.Status = types.DELEGATIONERROR
+		server.Error = err.Error()
+	case a.Rcode == dns.RcodeRefused:
+		server.Status = types.DELEGATIONREFUSED
+		server.Error = a.Err().Error()
+	case a.Rcode != dns.RcodeSuccess:
+		server.Status = types.DELEGATIONERROR
+		server.Error = a.Err().Error()
+	case !a.Authoritative:
+		server.Status = types.DELEGATIONLAME
+		server.Error = fmt.Sprintf("not authoritative for %s", zone)
+	default:
+		server.Nameservers = zoneNameservers(a, zone)
+		server.Status = types.DELEGATIONOK
+		if len(server.Nameservers) == 0 {
+			server.Status = types.DELEGATIONLAME
+			server.Error = fmt.Sprintf("no NS records for %s", zone)
+		}
+	}
+	return server
+}
+
+// zoneNameservers returns the sorted names of the NS records of the zone from the answer and authority sections
+func zoneNameservers(a *Answer, zone string) []string {
+	hosts := make([]string, 0)
+	for _, r := range Records[*dns.NS](append(append([]dns.RR{}, a.Answer...), a.Authority...)) {
+		if strings.EqualFold(r.Hdr.Name, zone) {
+			hosts = append(hosts, strings.ToLower(r.Ns))
+		}
+	}
+	return uniqueNames(hosts)
+}
+
+// uniqueNames returns the sorted names without duplicates
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, 0)
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// difference returns the names of a that aren't in b
+func difference(a []string, b []string) []string {
+	in := make(map[string]bool)
+	for _, name := range b {
+		in[name] = true
+	}
+	names := make([]string, 0)
+	for _, name := range a {
+		if !in[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isTimeout checks if the error is a timeout of the query
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
@@ -0,0 +1,93 @@
+package dnsutil
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestDelegationReport(t *testing.T) {
+	// 127.0.0.1 is both the upstream resolver, answering recursive queries, and the server of the test zone,
+	// delegating example.test to ns1 and ns2 while the child zone lists ns1 and ns3
+	upstream := dnstest.Zone(t, `
+test. 3600 IN SOA ns.test. hostmaster.test. 1 7200 3600 1209600 300
+test. 3600 IN NS ns.test.
+ns.test. 3600 IN A 127.0.0.1
+ns1.example.test. 3600 IN A 127.0.0.2
+ns2.example.test. 3600 IN A 127.0.0.3
+ns3.example.test. 3600 IN A 127.0.0.4
+ns4.example.test. 3600 IN A 127.0.0.5
+`)
+	parent := delegating(t, `
+example.test. 3600 IN NS ns1.example.test.
+example.test. 3600 IN NS ns2.example.test.
+`)
+	child := dnstest.Zone(t, `
+example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 3600 1209600 300
+example.test. 3600 IN NS ns1.example.test.
+example.test. 3600 IN NS ns3.example.test.
+`)
+	resolver := startNameservers(t,
+		func(w dns.ResponseWriter, r *dns.Msg) {
+			if r.RecursionDesired {
+				upstream(w, r)
+				return
+			}
+			parent(w, r)
+		},
+		child,
+		dnstest.Rcode(dns.RcodeRefused),
+		child,
+		parent,
+	)
+
+	nsInfo := types.NSInfo{Zone: "example.test.", Nameservers: []types.NameserverInfo{
+		{Name: "ns1.example.test."},
+		{Name: "ns3.example.test."},
+		{Name: "ns4.example.test."},
+	}}
+	report, err := DelegationReport(context.Background(), resolver, nsInfo)
+	if err != nil {
+		t.Fatalf("DelegationReport() error = %v", err)
+	}
+
+	if report.ParentZone != "test." || report.ParentServer != "ns.test. (127.0.0.1)" {
+		t.Errorf("parent = %s from %s, want test. from ns.test. (127.0.0.1)", report.ParentZone, report.ParentServer)
+	}
+	if want := []string{"ns1.example.test.", "ns2.example.test."}; !reflect.DeepEqual(report.ParentNameservers, want) {
+		t.Errorf("parent nameservers = %v, want %v", report.ParentNameservers, want)
+	}
+	if want := []string{"ns1.example.test.", "ns3.example.test."}; !reflect.DeepEqual(report.ChildNameservers, want) {
+		t.Errorf("child nameservers = %v, want %v", report.ChildNameservers, want)
+	}
+	if want := []string{"ns2.example.test."}; !reflect.DeepEqual(report.ParentOnly, want) {
+		t.Errorf("parent only = %v, want %v", report.ParentOnly, want)
+	}
+	if want := []string{"ns3.example.test."}; !reflect.DeepEqual(report.ChildOnly, want) {
+		t.Errorf("child only = %v, want %v", report.ChildOnly, want)
+	}
+	if !report.Mismatch {
+		t.Errorf("mismatch = false, want true")
+	}
+	if want := []string{"ns2.example.test. (127.0.0.3)", "ns4.example.test. (127.0.0.5)"}; !reflect.DeepEqual(report.Lame, want) {
+		t.Errorf("lame = %v, want %v", report.Lame, want)
+	}
+
+	statuses := make(map[string]string)
+	for _, server := range report.Servers {
+		statuses[server.Nameserver] = server.Status
+	}
+	want := map[string]string{
+		"ns1.example.test.": types.DELEGATIONOK,
+		"ns2.example.test.": types.DELEGATIONREFUSED,
+		"ns3.example.test.": types.DELEGATIONOK,
+		"ns4.example.test.": types.DELEGATIONLAME,
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
@@ -0,0 +1,89 @@
+package dnsutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DOHMEDIATYPE contains the media type of DNS wireformat messages sent over HTTPS
+const DOHMEDIATYPE = "application/dns-message"
+
+// isHTTPSAddress checks if the address is a DNS-over-HTTPS endpoint
+func isHTTPSAddress(address string) bool {
+	u, err := url.Parse(address)
+	return err == nil && u.Scheme == "https"
+}
+
+// exchangeHTTPS sends the message to a DNS-over-HTTPS endpoint according to RFC 8484
+func (r *Resolver) exchangeHTTPS(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	// The message ID is set to zero to make the GET requests cache friendly
+	m := msg.Copy()
+	m.Id = 0
+	b, err := m.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := r.newHTTPSRequest(ctx, b, address)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", DOHMEDIATYPE)
+
+	start := time.Now()
+	res, err := r.http.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("unexpected HTTP status %s", res.Status)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != DOHMEDIATYPE {
+		return nil, 0, fmt.Errorf("unexpected content type %q", ct)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	rtt := time.Since(start)
+
+	rsp := new(dns.Msg)
+	if err := rsp.Unpack(body); err != nil {
+		return nil, 0, err
+	}
+	rsp.Id = msg.Id
+	return rsp, rtt, nil
+}
+
+// newHTTPSRequest constructs the HTTP request carrying the wireformat message using the configured method
+func (r *Resolver) newHTTPSRequest(ctx context.Context, b []byte, address string) (*http.Request, error) {
+	if r.httpMethod == http.MethodPost {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", DOHMEDIATYPE)
+		return req, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("dns", base64.RawURLEncoding.EncodeToString(b))
+	u.RawQuery = q.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+}
@@ -0,0 +1,104 @@
+package dnsutil
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// dohHandler returns an HTTP handler answering DNS-over-HTTPS queries for A records with 192.0.2.1.
+// The query is checked to be sent with the method, and the response is written with the status and content type.
+func dohHandler(t *testing.T, method string, status int, contentType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != method {
+			t.Errorf("method = %s, want %s", req.Method, method)
+		}
+		if accept := req.Header.Get("Accept"); accept != DOHMEDIATYPE {
+			t.Errorf("Accept = %q, want %q", accept, DOHMEDIATYPE)
+		}
+
+		var b []byte
+		var err error
+		switch req.Method {
+		case http.MethodGet:
+			b, err = base64.RawURLEncoding.DecodeString(req.URL.Query().Get("dns"))
+		case http.MethodPost:
+			if ct := req.Header.Get("Content-Type"); ct != DOHMEDIATYPE {
+				t.Errorf("Content-Type = %q, want %q", ct, DOHMEDIATYPE)
+			}
+			b, err = ioutil.ReadAll(req.Body)
+		}
+		if err != nil {
+			t.Errorf("reading the query: %v", err)
+			return
+		}
+		msg := new(dns.Msg)
+		if err := msg.Unpack(b); err != nil {
+			t.Errorf("unpacking the query: %v", err)
+			return
+		}
+		if msg.Id != 0 {
+			t.Errorf("query ID = %d, want 0", msg.Id)
+		}
+
+		rsp := new(dns.Msg)
+		rsp.SetReply(msg)
+		rsp.Answer = append(rsp.Answer, &dns.A{Hdr: dns.RR_Header{Name: msg.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(192, 0, 2, 1)})
+		out, err := rsp.Pack()
+		if err != nil {
+			t.Errorf("packing the response: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(out)
+	}
+}
+
+func TestDoH(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		status      int
+		contentType string
+		wantErr     string
+	}{
+		{"get", http.MethodGet, http.StatusOK, DOHMEDIATYPE, ""},
+		{"post", http.MethodPost, http.StatusOK, DOHMEDIATYPE, ""},
+		{"status", http.MethodGet, http.StatusBadGateway, DOHMEDIATYPE, "unexpected HTTP status 502"},
+		{"content type", http.MethodPost, http.StatusOK, "text/html", `unexpected content type "text/html"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(dohHandler(t, tt.method, tt.status, tt.contentType))
+			defer server.Close()
+
+			address := server.URL + "/dns-query"
+			resolver := NewResolverWithOptions(types.Options{Resolvers: []string{address}, Timeout: time.Second, DoHMethod: tt.method})
+			resolver.http = server.Client()
+
+			res, err := resolver.LookupA(context.Background(), "www.example.com.")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LookupA() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LookupA() error = %v", err)
+			}
+			if len(res) != 1 || !res[0].A.Equal(net.IPv4(192, 0, 2, 1)) {
+				t.Errorf("LookupA() = %v, want 192.0.2.1", res)
+			}
+		})
+	}
+}
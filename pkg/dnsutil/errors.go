@@ -0,0 +1,71 @@
+package dnsutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNXDomain is matched by errors for queries whose domain doesn't exist
+var ErrNXDomain = errors.New("domain does not exist")
+
+// ErrNoData is matched by errors for queries whose domain exists without records of the requested type
+var ErrNoData = errors.New("no records of the requested type")
+
+// ErrServFail is matched by errors for queries the upstream resolver failed to answer
+var ErrServFail = errors.New("server failure")
+
+// RcodeError is returned for answers whose rcode isn't a success
+type RcodeError struct {
+	Name  string
+	Qtype uint16
+	Rcode int
+}
+
+// Error returns the query and the rcode of the answer
+func (e *RcodeError) Error() string {
+	return fmt.Sprintf("lookup %s %s: lookup code %s", e.Name, dns.TypeToString[e.Qtype], dns.RcodeToString[e.Rcode])
+}
+
+// Is matches ErrNXDomain and ErrServFail for the corresponding rcodes
+func (e *RcodeError) Is(target error) bool {
+	switch target {
+	case ErrNXDomain:
+		return e.Rcode == dns.RcodeNameError
+	case ErrServFail:
+		return e.Rcode == dns.RcodeServerFailure
+	}
+	return false
+}
+
+// IsAnswerError checks if the error comes from an answer that the domain or its records don't exist,
+// NXDOMAIN or NODATA, rather than from a failure to get an answer such as SERVFAIL or REFUSED
+func IsAnswerError(err error) bool {
+	return errors.Is(err, ErrNXDomain) || errors.Is(err, ErrNoData)
+}
+
+// firstFailure returns the first of the errors that isn't an NXDOMAIN or NODATA answer. Functions looking up several names
+// return it along with whatever was found, since names without records aren't a failure.
+func firstFailure(errs []error) error {
+	for _, err := range errs {
+		if err != nil && !IsAnswerError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Outcome returns the outcome of a query from its error: NOERROR, NODATA, the rcode of the answer or ERROR
+func Outcome(err error) string {
+	var rerr *RcodeError
+	switch {
+	case err == nil:
+		return "NOERROR"
+	case errors.Is(err, ErrNoData):
+		return "NODATA"
+	case errors.As(err, &rerr):
+		return dns.RcodeToString[rerr.Rcode]
+	}
+	return "ERROR"
+}
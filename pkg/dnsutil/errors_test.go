@@ -0,0 +1,76 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestIsAnswerError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"nxdomain", &RcodeError{Name: "example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError}, true},
+		{"nodata", fmt.Errorf("lookup example.com. A: %w", ErrNoData), true},
+		{"servfail", &RcodeError{Name: "example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure}, false},
+		{"refused", &RcodeError{Name: "example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeRefused}, false},
+		{"wrapped servfail", fmt.Errorf("ns1: %w", &RcodeError{Rcode: dns.RcodeServerFailure}), false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsAnswerError(tt.err); got != tt.want {
+				t.Errorf("IsAnswerError(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstFailure(t *testing.T) {
+	nxdomain := &RcodeError{Name: "example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError}
+	nodata := fmt.Errorf("lookup example.com. A: %w", ErrNoData)
+	servfail := &RcodeError{Name: "example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeServerFailure}
+	timeout := context.DeadlineExceeded
+	tests := []struct {
+		name string
+		errs []error
+		want error
+	}{
+		{"none", nil, nil},
+		{"successes", []error{nil, nil}, nil},
+		{"answers", []error{nxdomain, nil, nodata}, nil},
+		{"failure after answers", []error{nxdomain, servfail, timeout}, servfail},
+		{"first failure", []error{nil, timeout, servfail}, timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstFailure(tt.errs); got != tt.want {
+				t.Errorf("firstFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "NOERROR"},
+		{fmt.Errorf("lookup example.com. A: %w", ErrNoData), "NODATA"},
+		{&RcodeError{Rcode: dns.RcodeNameError}, "NXDOMAIN"},
+		{&RcodeError{Rcode: dns.RcodeServerFailure}, "SERVFAIL"},
+		{errors.New("connection refused"), "ERROR"},
+	}
+	for _, tt := range tests {
+		if got := Outcome(tt.err); got != tt.want {
+			t.Errorf("Outcome(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
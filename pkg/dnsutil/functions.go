@@ -1,26 +1,48 @@
 package dnsutil
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
-	"strconv"
+	"sort"
 	"strings"
 
+	"github.com/marc-barry/domaininfo/pkg/cache"
 	"github.com/marc-barry/domaininfo/pkg/ip"
 	"github.com/marc-barry/domaininfo/pkg/types"
 )
 
-// CNAMEChain produces a list containing the chain of CNAMES starting from the domain
-func CNAMEChain(resolver *Resolver, domain string) ([]string, error) {
+// SearchName returns the first name from the resolver's search list that exists
+func SearchName(ctx context.Context, resolver *Resolver, domain string) string {
+	names := resolver.NameList(domain)
+	if len(names) == 1 {
+		return domain
+	}
+
+	for _, name := range names {
+		if _, err := resolver.LookupA(ctx, name); err == nil || errors.Is(err, ErrNoData) {
+			return strings.TrimSuffix(name, ".")
+		}
+	}
+	return domain
+}
+
+// CNAMEChain produces a list containing the chain of CNAMES starting from the domain.
+// The chain ends at a name without a CNAME record, or at a target that doesn't exist.
+func CNAMEChain(ctx context.Context, resolver *Resolver, domain string) ([]string, error) {
 	targetsQueue := []string{domain}
 	domainToLookup := ""
 	targets := []string{}
 
 	for len(targetsQueue) != 0 {
 		domainToLookup, targetsQueue = targetsQueue[0], targetsQueue[1:]
-		lc, err := resolver.LookupCNAME(domainToLookup)
+		lc, err := resolver.LookupCNAME(ctx, domainToLookup)
+		if errors.Is(err, ErrNoData) || (errors.Is(err, ErrNXDomain) && domainToLookup != domain) {
+			continue
+		}
 		if err != nil {
-			return nil, err
+			return targets, err
 		}
 
 		for _, cname := range lc {
@@ -32,11 +54,11 @@ func CNAMEChain(resolver *Resolver, domain string) ([]string, error) {
 }
 
 // IPv4List returns a list of IPv4 addresses via A record lookups
-func IPv4List(resolver *Resolver, domain string) ([]net.IP, error) {
+func IPv4List(ctx context.Context, resolver *Resolver, domain string) ([]net.IP, error) {
 	ips := make([]net.IP, 0)
-	res, err := resolver.LookupA(domain)
+	res, err := resolver.LookupA(ctx, domain)
 	if err != nil {
-		return nil, err
+		return ips, err
 	}
 	for _, r := range res {
 		ips = append(ips, r.A)
@@ -45,11 +67,11 @@ func IPv4List(resolver *Resolver, domain string) ([]net.IP, error) {
 }
 
 // IPv6List returns a list of IPv4 addresses via AAAA record lookups
-func IPv6List(resolver *Resolver, domain string) ([]net.IP, error) {
+func IPv6List(ctx context.Context, resolver *Resolver, domain string) ([]net.IP, error) {
 	ips := make([]net.IP, 0)
-	res, err := resolver.LookupAAAA(domain)
+	res, err := resolver.LookupAAAA(ctx, domain)
 	if err != nil {
-		return nil, err
+		return ips, err
 	}
 	for _, r := range res {
 		ips = append(ips, r.AAAA)
@@ -57,54 +79,98 @@ func IPv6List(resolver *Resolver, domain string) ([]net.IP, error) {
 	return ips, nil
 }
 
-// AddressesInfos returns a an address into type based on IPv4 and IPv6 input lists
-func AddressesInfos(resolver *Resolver, ipv4s []net.IP, ipv6s []net.IP) (map[string][]types.ASNInfo, map[string][]types.ASNInfo, []string, error) {
-	asnsMap := make(map[string]string)
+// PTRNames returns the names an IP address points to via a PTR record lookup
+func PTRNames(ctx context.Context, resolver *Resolver, address net.IP) ([]string, error) {
+	names := make([]string, 0)
+	addr, err := ip.AddrFromIP(address)
+	if err != nil {
+		return names, err
+	}
+	reverse, err := ip.ARPAName(addr)
+	if err != nil {
+		return names, err
+	}
+	res, err := resolver.LookupPTR(ctx, reverse)
+	if err != nil {
+		return names, err
+	}
+	for _, r := range res {
+		names = append(names, r.Ptr)
+	}
+	return names, nil
+}
 
-	ipv4Info := make(map[string][]types.ASNInfo)
-	for _, ipv4 := range ipv4s {
-		ipv4Info[ipv4.String()] = make([]types.ASNInfo, 0)
-		if len(ipv4) != net.IPv4len {
-			ipv4 = ipv4[12:]
-		}
-		strs := make([]string, len(ipv4))
-		for i := 0; i < len(ipv4); i++ {
-			strs[len(ipv4)-1-i] = strconv.Itoa(int(ipv4[i]))
+// PTRInfos returns the forward-confirmed PTR info of each IP address keyed by address
+func PTRInfos(ctx context.Context, resolver *Resolver, addresses []net.IP) (map[string]types.PTRInfo, error) {
+	infos := make([]types.PTRInfo, len(addresses))
+	errs := make([]error, len(addresses))
+	forEach(len(addresses), resolver.concurrency, func(i int) {
+		infos[i], errs[i] = ptrInfo(ctx, resolver, addresses[i])
+	})
+
+	ptrInfos := make(map[string]types.PTRInfo)
+	for i, address := range addresses {
+		ptrInfos[address.String()] = infos[i]
+	}
+	return ptrInfos, firstFailure(errs)
+}
+
+// ptrInfo returns the PTR info of an IP address, checking that each name has an A or AAAA record pointing back to the address
+func ptrInfo(ctx context.Context, resolver *Resolver, address net.IP) (types.PTRInfo, error) {
+	info := types.PTRInfo{Names: make([]string, 0), Mismatches: make([]string, 0)}
+
+	names, err := PTRNames(ctx, resolver, address)
+	info.Outcome = Outcome(err)
+	if err := firstFailure([]error{err}); err != nil {
+		return info, err
+	}
+	info.Names = names
+
+	errs := make([]error, 0)
+	for _, name := range names {
+		var forward []net.IP
+		var err error
+		if address.To4() != nil {
+			forward, err = IPv4List(ctx, resolver, name)
+		} else {
+			forward, err = IPv6List(ctx, resolver, name)
 		}
-		if res, err := resolver.LookupTXT(fmt.Sprintf(types.IPv4LOOKUPTEMPLATE, strings.Join(strs, "."))); err == nil {
-			for _, r := range res {
-				for _, txt := range r.Txt {
-					sp := strings.Split(txt, " | ")
-					if len(sp) == 5 {
-						ipv4Info[ipv4.String()] = append(ipv4Info[ipv4.String()], types.ASNInfo{ASN: sp[0], AddressBlock: sp[1], Country: sp[2], InternetRegistry: sp[3], Date: sp[4]})
-						asnsMap[sp[0]] = sp[0]
-					}
-				}
+		errs = append(errs, err)
+
+		confirmed := false
+		for _, f := range forward {
+			if f.Equal(address) {
+				confirmed = true
 			}
 		}
+		if confirmed {
+			info.ForwardConfirmed = true
+		} else {
+			info.Mismatches = append(info.Mismatches, name)
+		}
 	}
+	return info, firstFailure(errs)
+}
+
+// AddressesInfos returns a an address into type based on IPv4 and IPv6 input lists, along with the sorted ASNs
+func AddressesInfos(ctx context.Context, resolver *Resolver, store *cache.Store, ipv4s []net.IP, ipv6s []net.IP) (map[string][]types.ASNInfo, map[string][]types.ASNInfo, []string, error) {
+	addresses := append(append([]net.IP{}, ipv4s...), ipv6s...)
+	infos, errs := AddressesOrigins(ctx, resolver, store, addresses)
+
+	asnsMap := make(map[string]string)
 
+	ipv4Info := make(map[string][]types.ASNInfo)
 	ipv6Info := make(map[string][]types.ASNInfo)
-	for _, ipv6 := range ipv6s {
-		ipv6Info[ipv6.String()] = make([]types.ASNInfo, 0)
-		ipv6decom, err := ip.UncompressedIPv6(ipv6)
-		if err != nil {
-			continue
+	for i, address := range addresses {
+		addressInfo := ipv4Info
+		if i >= len(ipv4s) {
+			addressInfo = ipv6Info
 		}
-		ipv6decomstrip := strings.ReplaceAll(ipv6decom, ":", "")
-		strs := make([]string, len(ipv6decomstrip))
-		for i := 0; i < len(ipv6decomstrip); i++ {
-			strs[len(ipv6decomstrip)-1-i] = string(ipv6decomstrip[i])
-		}
-		if res, err := resolver.LookupTXT(fmt.Sprintf(types.IPv6LOOKUPTEMPLATE, strings.Join(strs, "."))); err == nil {
-			for _, r := range res {
-				for _, txt := range r.Txt {
-					sp := strings.Split(txt, " | ")
-					if len(sp) == 5 {
-						ipv6Info[ipv6.String()] = append(ipv6Info[ipv6.String()], types.ASNInfo{ASN: sp[0], AddressBlock: sp[1], Country: sp[2], InternetRegistry: sp[3], Date: sp[4]})
-						asnsMap[sp[0]] = sp[0]
-					}
-				}
+		addressInfo[address.String()] = make([]types.ASNInfo, 0)
+		for _, info := range infos[i] {
+			addressInfo[address.String()] = append(addressInfo[address.String()], info)
+			for _, asn := range OriginASNs(info) {
+				asnsMap[asn] = asn
 			}
 		}
 	}
@@ -113,40 +179,119 @@ func AddressesInfos(resolver *Resolver, ipv4s []net.IP, ipv6s []net.IP) (map[str
 	for k := range asnsMap {
 		asns = append(asns, k)
 	}
+	sort.Strings(asns)
+
+	return ipv4Info, ipv6Info, asns, firstFailure(errs)
+}
 
-	return ipv4Info, ipv6Info, asns, nil
+// OriginASNs returns the ASNs of an origin info, which lists all of them separated by spaces for addresses announced by multiple ASNs
+func OriginASNs(info types.ASNInfo) []string {
+	return strings.Fields(info.ASN)
 }
 
-// ASNDescriptions returns a list of ASN descriptions
-func ASNDescriptions(resolver *Resolver, asns []string) []types.ASNDescription {
+// AddressesOrigins returns the origin ASN infos and the lookup error of each address, so that failed lookups can be told apart from unannounced addresses
+func AddressesOrigins(ctx context.Context, resolver *Resolver, store *cache.Store, addresses []net.IP) ([][]types.ASNInfo, []error) {
+	infos := make([][]types.ASNInfo, len(addresses))
+	errs := make([]error, len(addresses))
+	forEach(len(addresses), resolver.concurrency, func(i int) {
+		addr, err := ip.AddrFromIP(addresses[i])
+		if err != nil {
+			infos[i], errs[i] = make([]types.ASNInfo, 0), err
+			return
+		}
+		zone := types.IPv6ORIGINLOOKUPDNSSERVER
+		if addr.Is4() {
+			zone = types.IPv4ORIGINLOOKUPDNSSERVER
+		}
+		name, err := ip.ReverseName(addr, zone)
+		if err != nil {
+			infos[i], errs[i] = make([]types.ASNInfo, 0), err
+			return
+		}
+		infos[i], errs[i] = originInfos(ctx, resolver, store, addresses[i].String(), name)
+	})
+	return infos, errs
+}
+
+// originInfos returns the origin ASN infos for an address from the TXT records of its Cymru origin name
+func originInfos(ctx context.Context, resolver *Resolver, store *cache.Store, address string, name string) ([]types.ASNInfo, error) {
+	infos := make([]types.ASNInfo, 0)
+	key := "origin/" + address
+	if store.Get(key, &infos) {
+		return infos, nil
+	}
+
+	res, err := resolver.LookupTXT(ctx, name)
+	if err := firstFailure([]error{err}); err != nil {
+		return infos, err
+	}
+	for _, r := range res {
+		for _, txt := range r.Txt {
+			sp := strings.Split(txt, " | ")
+			if len(sp) == 5 {
+				infos = append(infos, types.ASNInfo{ASN: sp[0], AddressBlock: sp[1], Country: sp[2], InternetRegistry: sp[3], Date: sp[4]})
+			}
+		}
+	}
+	resolver.logError(store.Put(key, infos))
+	return infos, nil
+}
+
+// ASNDescriptions returns a list of ASN descriptions in the order of the ASNs
+func ASNDescriptions(ctx context.Context, resolver *Resolver, store *cache.Store, asns []string) ([]types.ASNDescription, error) {
+	descriptions := make([][]types.ASNDescription, len(asns))
+	errs := make([]error, len(asns))
+	forEach(len(asns), resolver.concurrency, func(i int) {
+		descriptions[i], errs[i] = describeASN(ctx, resolver, store, asns[i])
+	})
+
 	asnDescriptions := make([]types.ASNDescription, 0)
+	for i := range asns {
+		asnDescriptions = append(asnDescriptions, descriptions[i]...)
+	}
+	return asnDescriptions, firstFailure(errs)
+}
 
-	for _, asn := range asns {
-		if res, err := resolver.LookupTXT(fmt.Sprintf(types.ASNLOOKUPTEMPLATE, asn)); err == nil {
-			for _, r := range res {
-				for _, txt := range r.Txt {
-					sp := strings.Split(txt, " | ")
-					asnDescriptions = append(asnDescriptions, types.ASNDescription{ASN: sp[0], Country: sp[1], InternetRegistry: sp[2], Date: sp[3], Org: sp[4]})
-				}
+// describeASN returns the descriptions of an ASN from the TXT records of its Cymru name
+func describeASN(ctx context.Context, resolver *Resolver, store *cache.Store, asn string) ([]types.ASNDescription, error) {
+	descriptions := make([]types.ASNDescription, 0)
+	key := "asn/" + asn
+	if store.Get(key, &descriptions) {
+		return descriptions, nil
+	}
+
+	res, err := resolver.LookupTXT(ctx, fmt.Sprintf(types.ASNLOOKUPTEMPLATE, asn))
+	if err := firstFailure([]error{err}); err != nil {
+		return descriptions, err
+	}
+	for _, r := range res {
+		for _, txt := range r.Txt {
+			sp := strings.Split(txt, " | ")
+			if len(sp) == 5 {
+				descriptions = append(descriptions, types.ASNDescription{ASN: sp[0], Country: sp[1], InternetRegistry: sp[2], Date: sp[3], Org: sp[4]})
 			}
 		}
 	}
-	return asnDescriptions
+	resolver.logError(store.Put(key, descriptions))
+	return descriptions, nil
 }
 
 // CAAInfos returns a list CAA info
-func CAAInfos(resolver *Resolver, domain string, targets []string) []types.CAAInfo {
+func CAAInfos(ctx context.Context, resolver *Resolver, domain string, targets []string) ([]types.CAAInfo, error) {
 	caaInfos := make([]types.CAAInfo, 0)
 	found := false
+	var firstErr error
 
-	res, err := resolver.LookupCAA(domain)
-	if err == nil {
+	res, err := resolver.LookupCAA(ctx, domain)
+	if err == nil || errors.Is(err, ErrNoData) {
 		info := types.CAAInfo{Domain: domain, CAs: make([]string, 0)}
 		for _, r := range res {
 			found = true
 			info.CAs = append(info.CAs, r.Value)
 		}
 		caaInfos = append(caaInfos, info)
+	} else if !IsAnswerError(err) {
+		firstErr = err
 	}
 
 	if !found {
@@ -154,8 +299,11 @@ func CAAInfos(resolver *Resolver, domain string, targets []string) []types.CAAIn
 			if i == 7 {
 				break
 			}
-			res, err := resolver.LookupCAA(domain)
-			if err != nil {
+			res, err := resolver.LookupCAA(ctx, domain)
+			if err != nil && !errors.Is(err, ErrNoData) {
+				if firstErr == nil && !IsAnswerError(err) {
+					firstErr = err
+				}
 				continue
 			}
 			info := types.CAAInfo{Domain: domain, CAs: make([]string, 0)}
@@ -172,16 +320,18 @@ func CAAInfos(resolver *Resolver, domain string, targets []string) []types.CAAIn
 			i := strings.IndexAny(domain, ".")
 			if i > 0 {
 				parent := domain[i+1:]
-				res, err := resolver.LookupCAA(parent)
-				if err == nil {
+				res, err := resolver.LookupCAA(ctx, parent)
+				if err == nil || errors.Is(err, ErrNoData) {
 					info := types.CAAInfo{Domain: parent, CAs: make([]string, 0)}
 					for _, r := range res {
 						info.CAs = append(info.CAs, r.Value)
 					}
 					caaInfos = append(caaInfos, info)
+				} else if firstErr == nil && !IsAnswerError(err) {
+					firstErr = err
 				}
 			}
 		}
 	}
-	return caaInfos
+	return caaInfos, firstErr
 }
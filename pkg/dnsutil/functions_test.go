@@ -0,0 +1,283 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/cache"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// testResolver returns a resolver using the DNS server at the address without retries or caching
+func testResolver(address string) *Resolver {
+	return NewResolverWithOptions(types.Options{Resolvers: []string{address}, Timeout: time.Second})
+}
+
+func TestASNLookupsStoredOnlyForAnswers(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   dns.HandlerFunc
+		wantErr   bool
+		wantStore bool
+	}{
+		{"servfail", dnstest.Rcode(dns.RcodeServerFailure), true, false},
+		{"refused", dnstest.Rcode(dns.RcodeRefused), true, false},
+		{"nxdomain", dnstest.Rcode(dns.RcodeNameError), false, true},
+		{"noerror", dnstest.Zone(t, `
+8.8.8.8.origin.asn.cymru.com. 300 IN TXT "15169 | 8.8.8.0/24 | US | arin | 1992-12-01"
+AS15169.asn.cymru.com. 300 IN TXT "15169 | US | arin | 2000-03-30 | GOOGLE, US"
+`), false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := testResolver(dnstest.Start(t, "127.0.0.1:0", tt.handler))
+			store, err := cache.NewStore(t.TempDir(), time.Hour, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = originInfos(context.Background(), resolver, store, "8.8.8.8", "8.8.8.8.origin.asn.cymru.com.")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("originInfos() error = %v, want error %t", err, tt.wantErr)
+			}
+			var infos []types.ASNInfo
+			if got := store.Get("origin/8.8.8.8", &infos); got != tt.wantStore {
+				t.Errorf("origin stored = %t, want %t", got, tt.wantStore)
+			}
+
+			_, err = describeASN(context.Background(), resolver, store, "15169")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("describeASN() error = %v, want error %t", err, tt.wantErr)
+			}
+			var descriptions []types.ASNDescription
+			if got := store.Get("asn/15169", &descriptions); got != tt.wantStore {
+				t.Errorf("description stored = %t, want %t", got, tt.wantStore)
+			}
+		})
+	}
+}
+
+// slowCymru returns a handler answering Cymru origin and ASN names after a delay that varies by name,
+// so that concurrent lookups complete out of order. The most queries in flight at once is kept in maxInFlight.
+func slowCymru(maxInFlight *int32) dns.HandlerFunc {
+	var inFlight int32
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(maxInFlight, max, n) {
+				break
+			}
+		}
+
+		q := r.Question[0]
+		labels := dns.SplitDomainName(q.Name)
+		time.Sleep(time.Duration(len(q.Name)*7%11) * time.Millisecond)
+
+		m := new(dns.Msg)
+		m.SetReply(r)
+		var txt string
+		switch {
+		case strings.HasSuffix(q.Name, ".origin.asn.cymru.com."):
+			last, _ := strconv.Atoi(labels[0])
+			txt = fmt.Sprintf("%d | 10.0.%s.0/24 | US | arin | 2000-01-01", 64500+last%5, labels[1])
+		case strings.HasSuffix(q.Name, ".origin6.asn.cymru.com."):
+			txt = "64510 | 2001:db8::/32 | EU | ripencc | 2000-01-01"
+		case strings.HasSuffix(q.Name, ".asn.cymru.com."):
+			txt = strings.TrimPrefix(labels[0], "AS") + " | US | arin | 2000-01-01 | EXAMPLE-" + labels[0] + ", US"
+		}
+		m.Answer = append(m.Answer, &dns.TXT{Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 300}, Txt: []string{txt}})
+		w.WriteMsg(m)
+	}
+}
+
+func TestAddressesInfosConcurrent(t *testing.T) {
+	var maxInFlight int32
+	address := dnstest.Start(t, "127.0.0.1:0", slowCymru(&maxInFlight))
+	const concurrency = 4
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{address}, Timeout: time.Second, Concurrency: concurrency})
+
+	ipv4s := make([]net.IP, 0)
+	for i := 0; i < 40; i++ {
+		ipv4s = append(ipv4s, net.IPv4(10, 0, byte(i/8), byte(i)))
+	}
+	ipv6s := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}
+
+	wantASNs := []string{"64500", "64501", "64502", "64503", "64504", "64510"}
+	for run := 0; run < 3; run++ {
+		ipv4Info, ipv6Info, asns, err := AddressesInfos(context.Background(), resolver, nil, ipv4s, ipv6s)
+		if err != nil {
+			t.Fatalf("AddressesInfos() error = %v", err)
+		}
+		if !reflect.DeepEqual(asns, wantASNs) {
+			t.Errorf("AddressesInfos() ASNs = %v, want %v", asns, wantASNs)
+		}
+		if len(ipv4Info) != len(ipv4s) || len(ipv6Info) != len(ipv6s) {
+			t.Fatalf("AddressesInfos() returned %d IPv4 and %d IPv6 infos, want %d and %d", len(ipv4Info), len(ipv6Info), len(ipv4s), len(ipv6s))
+		}
+		for i, a := range ipv4s {
+			infos := ipv4Info[a.String()]
+			want := strconv.Itoa(64500 + i%5)
+			if len(infos) != 1 || infos[0].ASN != want {
+				t.Errorf("infos of %s = %v, want ASN %s", a, infos, want)
+			}
+		}
+
+		descriptions, err := ASNDescriptions(context.Background(), resolver, nil, asns)
+		if err != nil {
+			t.Fatalf("ASNDescriptions() error = %v", err)
+		}
+		got := make([]string, 0)
+		for _, d := range descriptions {
+			got = append(got, d.ASN)
+		}
+		if !reflect.DeepEqual(got, wantASNs) {
+			t.Errorf("ASNDescriptions() ASNs = %v, want %v", got, wantASNs)
+		}
+	}
+
+	if got := atomic.LoadInt32(&maxInFlight); got > concurrency {
+		t.Errorf("%d queries were in flight at once, want at most %d", got, concurrency)
+	}
+}
+
+func TestConcurrencySharedByLookups(t *testing.T) {
+	var maxInFlight int32
+	address := dnstest.Start(t, "127.0.0.1:0", slowCymru(&maxInFlight))
+	const concurrency = 3
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{address}, Timeout: time.Second, Concurrency: concurrency})
+
+	ipv4s := make([]net.IP, 0)
+	for i := 0; i < 16; i++ {
+		ipv4s = append(ipv4s, net.IPv4(10, 0, 0, byte(i)))
+	}
+
+	// Fan-outs running at the same time, like the sections of a domain or the domains of a bulk run, share the limit
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, _, _, err := AddressesInfos(context.Background(), resolver, nil, ipv4s, nil); err != nil {
+				t.Errorf("AddressesInfos() error = %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := ASNDescriptions(context.Background(), resolver, nil, []string{"64500", "64501", "64502", "64503"}); err != nil {
+				t.Errorf("ASNDescriptions() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&maxInFlight); got > concurrency {
+		t.Errorf("%d queries were in flight at once, want at most %d", got, concurrency)
+	}
+}
+
+func TestAddressesInfosMultipleOrigins(t *testing.T) {
+	var mu sync.Mutex
+	queried := make([]string, 0)
+	zone := dnstest.Zone(t, `
+1.1.1.1.origin.asn.cymru.com. 300 IN TXT "13335 209242 | 1.1.1.0/24 | AU | apnic | 2011-08-11"
+8.8.8.8.origin.asn.cymru.com. 300 IN TXT "15169 | 8.8.8.0/24 | US | arin | 1992-12-01"
+AS13335.asn.cymru.com. 300 IN TXT "13335 | US | arin | 2010-07-14 | CLOUDFLARENET, US"
+AS209242.asn.cymru.com. 300 IN TXT "209242 | GB | ripencc | 2019-12-18 | CLOUDFLARESPECTRUM, GB"
+AS15169.asn.cymru.com. 300 IN TXT "15169 | US | arin | 2000-03-30 | GOOGLE, US"
+`)
+	address := dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+		mu.Lock()
+		queried = append(queried, r.Question[0].Name)
+		mu.Unlock()
+		zone(w, r)
+	})
+	resolver := testResolver(address)
+
+	ipv4Info, _, asns, err := AddressesInfos(context.Background(), resolver, nil, []net.IP{net.IPv4(1, 1, 1, 1), net.IPv4(8, 8, 8, 8)}, nil)
+	if err != nil {
+		t.Fatalf("AddressesInfos() error = %v", err)
+	}
+	if want := []string{"13335", "15169", "209242"}; !reflect.DeepEqual(asns, want) {
+		t.Errorf("AddressesInfos() ASNs = %v, want %v", asns, want)
+	}
+	if infos := ipv4Info["1.1.1.1"]; len(infos) != 1 || !reflect.DeepEqual(OriginASNs(infos[0]), []string{"13335", "209242"}) {
+		t.Errorf("infos of 1.1.1.1 = %v, want one info originated by 13335 and 209242", infos)
+	}
+
+	descriptions, err := ASNDescriptions(context.Background(), resolver, nil, asns)
+	if err != nil {
+		t.Fatalf("ASNDescriptions() error = %v", err)
+	}
+	orgs := make([]string, 0)
+	for _, d := range descriptions {
+		orgs = append(orgs, d.Org)
+	}
+	if want := []string{"CLOUDFLARENET, US", "GOOGLE, US", "CLOUDFLARESPECTRUM, GB"}; !reflect.DeepEqual(orgs, want) {
+		t.Errorf("ASNDescriptions() orgs = %v, want %v", orgs, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range queried {
+		if strings.Contains(name, " ") {
+			t.Errorf("queried %q, want a name per ASN", name)
+		}
+	}
+}
+
+func TestPTRInfos(t *testing.T) {
+	address := dnstest.Start(t, "127.0.0.1:0", dnstest.Zone(t, `
+1.2.0.192.in-addr.arpa. 300 IN PTR www.example.com.
+2.2.0.192.in-addr.arpa. 300 IN PTR other.example.com.
+3.2.0.192.in-addr.arpa. 300 IN PTR www.example.com.
+3.2.0.192.in-addr.arpa. 300 IN PTR mail.example.com.
+1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa. 300 IN PTR www.example.com.
+www.example.com. 300 IN A 192.0.2.1
+www.example.com. 300 IN A 192.0.2.3
+www.example.com. 300 IN AAAA 2001:db8::1
+other.example.com. 300 IN A 198.51.100.1
+mail.example.com. 300 IN AAAA 2001:db8::25
+`))
+	resolver := testResolver(address)
+
+	addresses := []net.IP{net.IPv4(192, 0, 2, 1), net.IPv4(192, 0, 2, 2), net.IPv4(192, 0, 2, 3), net.IPv4(192, 0, 2, 4), net.ParseIP("2001:db8::1")}
+	infos, err := PTRInfos(context.Background(), resolver, addresses)
+	if err != nil {
+		t.Fatalf("PTRInfos() error = %v", err)
+	}
+
+	want := map[string]types.PTRInfo{
+		"192.0.2.1":   {Outcome: "NOERROR", Names: []string{"www.example.com."}, ForwardConfirmed: true, Mismatches: []string{}},
+		"192.0.2.2":   {Outcome: "NOERROR", Names: []string{"other.example.com."}, ForwardConfirmed: false, Mismatches: []string{"other.example.com."}},
+		"192.0.2.3":   {Outcome: "NOERROR", Names: []string{"www.example.com.", "mail.example.com."}, ForwardConfirmed: true, Mismatches: []string{"mail.example.com."}},
+		"192.0.2.4":   {Outcome: "NXDOMAIN", Names: []string{}, ForwardConfirmed: false, Mismatches: []string{}},
+		"2001:db8::1": {Outcome: "NOERROR", Names: []string{"www.example.com."}, ForwardConfirmed: true, Mismatches: []string{}},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("PTRInfos() = %+v, want %+v", infos, want)
+	}
+}
+
+func TestPTRInfosServerFailure(t *testing.T) {
+	resolver := testResolver(dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure)))
+
+	infos, err := PTRInfos(context.Background(), resolver, []net.IP{net.IPv4(192, 0, 2, 1)})
+	if err == nil {
+		t.Errorf("PTRInfos() error = nil, want the server failure")
+	}
+	if got := infos["192.0.2.1"].Outcome; got != "SERVFAIL" {
+		t.Errorf("outcome = %q, want SERVFAIL", got)
+	}
+}
@@ -0,0 +1,92 @@
+package dnsutil
+
+import (
+	"context"
+	"net"
+	"sort"
+
+	"github.com/marc-barry/domaininfo/pkg/cache"
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// hostInfo contains the ASN info of the IP addresses of a host
+type hostInfo struct {
+	ipv4Info map[string][]types.ASNInfo
+	ipv6Info map[string][]types.ASNInfo
+}
+
+// hostAddresses returns the IPv4 and IPv6 addresses of each host in the order of the hosts
+func hostAddresses(ctx context.Context, resolver *Resolver, hosts []string) ([][]net.IP, [][]net.IP, error) {
+	ipv4s := make([][]net.IP, len(hosts))
+	ipv6s := make([][]net.IP, len(hosts))
+	errs := make([]error, 2*len(hosts))
+	forEach(2*len(hosts), resolver.concurrency, func(i int) {
+		if i < len(hosts) {
+			ipv4s[i], errs[i] = IPv4List(ctx, resolver, hosts[i])
+		} else {
+			ipv6s[i-len(hosts)], errs[i] = IPv6List(ctx, resolver, hosts[i-len(hosts)])
+		}
+	})
+
+	return ipv4s, ipv6s, firstFailure(errs)
+}
+
+// hostsInfos returns the ASN info of the IP addresses of each host in the order of the hosts, along with the sorted ASNs
+func hostsInfos(ctx context.Context, resolver *Resolver, store *cache.Store, hosts []string) ([]hostInfo, []string, error) {
+	ipv4s, ipv6s, addressesErr := hostAddresses(ctx, resolver, hosts)
+
+	allIPv4s := make([]net.IP, 0)
+	allIPv6s := make([]net.IP, 0)
+	for i := range hosts {
+		allIPv4s = append(allIPv4s, ipv4s[i]...)
+		allIPv6s = append(allIPv6s, ipv6s[i]...)
+	}
+	ipv4Info, ipv6Info, asns, err := AddressesInfos(ctx, resolver, store, allIPv4s, allIPv6s)
+
+	infos := make([]hostInfo, len(hosts))
+	for i := range hosts {
+		infos[i] = hostInfo{ipv4Info: make(map[string][]types.ASNInfo), ipv6Info: make(map[string][]types.ASNInfo)}
+		for _, address := range ipv4s[i] {
+			infos[i].ipv4Info[address.String()] = ipv4Info[address.String()]
+		}
+		for _, address := range ipv6s[i] {
+			infos[i].ipv6Info[address.String()] = ipv6Info[address.String()]
+		}
+	}
+	return infos, asns, firstFailure([]error{addressesErr, err})
+}
+
+// MXInfos returns the mail exchangers of a domain sorted by preference with the ASN info of their IP addresses, skipping a null MX
+func MXInfos(ctx context.Context, resolver *Resolver, store *cache.Store, domain string) ([]types.MXInfo, []string, error) {
+	mxInfos := make([]types.MXInfo, 0)
+	res, err := resolver.LookupMX(ctx, domain)
+	if err != nil {
+		return mxInfos, make([]string, 0), err
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Preference != res[j].Preference {
+			return res[i].Preference < res[j].Preference
+		}
+		return res[i].Mx < res[j].Mx
+	})
+
+	hosts := make([]string, 0)
+	for _, r := range res {
+		if r.Mx != "." {
+			hosts = append(hosts, r.Mx)
+		}
+	}
+	infos, asns, err := hostsInfos(ctx, resolver, store, hosts)
+
+	i := 0
+	for _, r := range res {
+		mxInfo := types.MXInfo{Exchange: r.Mx, Preference: r.Preference, IPv4AddressInfo: make(map[string][]types.ASNInfo), IPv6AddressInfo: make(map[string][]types.ASNInfo)}
+		if r.Mx != "." {
+			mxInfo.IPv4AddressInfo = infos[i].ipv4Info
+			mxInfo.IPv6AddressInfo = infos[i].ipv6Info
+			i++
+		}
+		mxInfos = append(mxInfos, mxInfo)
+	}
+	return mxInfos, asns, err
+}
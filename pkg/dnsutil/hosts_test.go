@@ -0,0 +1,60 @@
+package dnsutil
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestMXInfos(t *testing.T) {
+	var rootQueries int32
+	zone := dnstest.Zone(t, `
+example.com. 300 IN MX 20 mx2.example.com.
+example.com. 300 IN MX 10 mxb.example.com.
+example.com. 300 IN MX 0 .
+example.com. 300 IN MX 10 mx1.example.com.
+mx1.example.com. 300 IN A 192.0.2.1
+mx1.example.com. 300 IN AAAA 2001:db8::1
+mx2.example.com. 300 IN A 192.0.2.2
+1.2.0.192.origin.asn.cymru.com. 300 IN TXT "64496 | 192.0.2.0/24 | US | arin | 2010-07-14"
+2.2.0.192.origin.asn.cymru.com. 300 IN TXT "64497 | 192.0.2.0/24 | GB | ripencc | 2019-12-18"
+`)
+	address := dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Name == "." {
+			atomic.AddInt32(&rootQueries, 1)
+		}
+		zone(w, r)
+	})
+	resolver := testResolver(address)
+
+	infos, asns, err := MXInfos(context.Background(), resolver, nil, "example.com.")
+	if err != nil {
+		t.Fatalf("MXInfos() error = %v", err)
+	}
+	if want := []string{"64496", "64497"}; !reflect.DeepEqual(asns, want) {
+		t.Errorf("MXInfos() ASNs = %v, want %v", asns, want)
+	}
+
+	none := map[string][]types.ASNInfo{}
+	want := []types.MXInfo{
+		{Exchange: ".", Preference: 0, IPv4AddressInfo: none, IPv6AddressInfo: none},
+		{Exchange: "mx1.example.com.", Preference: 10,
+			IPv4AddressInfo: map[string][]types.ASNInfo{"192.0.2.1": {{ASN: "64496", AddressBlock: "192.0.2.0/24", Country: "US", InternetRegistry: "arin", Date: "2010-07-14"}}},
+			IPv6AddressInfo: map[string][]types.ASNInfo{"2001:db8::1": {}}},
+		{Exchange: "mxb.example.com.", Preference: 10, IPv4AddressInfo: none, IPv6AddressInfo: none},
+		{Exchange: "mx2.example.com.", Preference: 20,
+			IPv4AddressInfo: map[string][]types.ASNInfo{"192.0.2.2": {{ASN: "64497", AddressBlock: "192.0.2.0/24", Country: "GB", InternetRegistry: "ripencc", Date: "2019-12-18"}}},
+			IPv6AddressInfo: none},
+	}
+	if !reflect.DeepEqual(infos, want) {
+		t.Errorf("MXInfos() = %+v, want %+v", infos, want)
+	}
+	if n := atomic.LoadInt32(&rootQueries); n != 0 {
+		t.Errorf("the null MX was looked up %d times", n)
+	}
+}
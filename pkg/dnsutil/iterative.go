@@ -0,0 +1,318 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// MAXREFERRALS contains the number of referrals followed when resolving a name iteratively
+const MAXREFERRALS = 16
+
+// MAXITERATIONDEPTH contains the number of nested resolutions of aliases and nameservers without glue
+// made when resolving a name iteratively
+const MAXITERATIONDEPTH = 8
+
+// ROOTHINTS contains the root servers used when no root hints file is given, in the named.root format
+const ROOTHINTS = `
+.                        3600000      NS    A.ROOT-SERVERS.NET.
+A.ROOT-SERVERS.NET.      3600000      A     198.41.0.4
+A.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:ba3e::2:30
+.                        3600000      NS    B.ROOT-SERVERS.NET.
+B.ROOT-SERVERS.NET.      3600000      A     170.247.170.2
+B.ROOT-SERVERS.NET.      3600000      AAAA  2801:1b8:10::b
+.                        3600000      NS    C.ROOT-SERVERS.NET.
+C.ROOT-SERVERS.NET.      3600000      A     192.33.4.12
+C.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2::c
+.                        3600000      NS    D.ROOT-SERVERS.NET.
+D.ROOT-SERVERS.NET.      3600000      A     199.7.91.13
+D.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2d::d
+.                        3600000      NS    E.ROOT-SERVERS.NET.
+E.ROOT-SERVERS.NET.      3600000      A     192.203.230.10
+E.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:a8::e
+.                        3600000      NS    F.ROOT-SERVERS.NET.
+F.ROOT-SERVERS.NET.      3600000      A     192.5.5.241
+F.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2f::f
+.                        3600000      NS    G.ROOT-SERVERS.NET.
+G.ROOT-SERVERS.NET.      3600000      A     192.112.36.4
+G.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:12::d0d
+.                        3600000      NS    H.ROOT-SERVERS.NET.
+H.ROOT-SERVERS.NET.      3600000      A     198.97.190.53
+H.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:1::53
+.                        3600000      NS    I.ROOT-SERVERS.NET.
+I.ROOT-SERVERS.NET.      3600000      A     192.36.148.17
+I.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fe::53
+.                        3600000      NS    J.ROOT-SERVERS.NET.
+J.ROOT-SERVERS.NET.      3600000      A     192.58.128.30
+J.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:c27::2:30
+.                        3600000      NS    K.ROOT-SERVERS.NET.
+K.ROOT-SERVERS.NET.      3600000      A     193.0.14.129
+K.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fd::1
+.                        3600000      NS    L.ROOT-SERVERS.NET.
+L.ROOT-SERVERS.NET.      3600000      A     199.7.83.42
+L.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:9f::42
+.                        3600000      NS    M.ROOT-SERVERS.NET.
+M.ROOT-SERVERS.NET.      3600000      A     202.12.27.33
+M.ROOT-SERVERS.NET.      3600000      AAAA  2001:dc3::35
+`
+
+// NewIterativeResolver constructs a new DNS resolver resolving names itself by following the referrals
+// from the root servers of the root hints file in the named.root format, or the built-in root hints when none is given.
+// Servers given without a port in the root hints file, and the nameservers they refer to, use the port from the options.
+func NewIterativeResolver(opts types.Options) (*Resolver, error) {
+	hints := ROOTHINTS
+	if opts.RootHints != "" {
+		b, err := ioutil.ReadFile(opts.RootHints)
+		if err != nil {
+			return nil, err
+		}
+		hints = string(b)
+	}
+
+	roots, err := parseRootHints(hints)
+	if err != nil {
+		return nil, fmt.Errorf("parsing root hints: %v", err)
+	}
+
+	r := newResolver(nil, opts)
+	r.roots = roots
+	if opts.Port != 0 {
+		r.nameserverPort = opts.Port
+	}
+	return r, nil
+}
+
+// parseRootHints returns the addresses of the root servers from root hints in the named.root format, IPv4 addresses first
+func parseRootHints(hints string) ([]nameserverAddress, error) {
+	hosts := make([]string, 0)
+	ipv4s := make(map[string][]string)
+	ipv6s := make(map[string][]string)
+
+	zp := dns.NewZoneParser(strings.NewReader(hints), ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := strings.ToLower(rr.Header().Name)
+		switch rr := rr.(type) {
+		case *dns.NS:
+			if name == "." {
+				hosts = append(hosts, strings.ToLower(rr.Ns))
+			}
+		case *dns.A:
+			ipv4s[name] = append(ipv4s[name], rr.A.String())
+		case *dns.AAAA:
+			ipv6s[name] = append(ipv6s[name], rr.AAAA.String())
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+
+	roots := make([]nameserverAddress, 0)
+	for _, host := range hosts {
+		for _, address := range ipv4s[host] {
+			roots = append(roots, nameserverAddress{name: host, address: address})
+		}
+	}
+	for _, host := range hosts {
+		for _, address := range ipv6s[host] {
+			roots = append(roots, nameserverAddress{name: host, address: address})
+		}
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no root server addresses found")
+	}
+	return roots, nil
+}
+
+// iterate resolves the question of the message iteratively from the root servers
+func (r *Resolver) iterate(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	return r.resolveIteratively(ctx, msg.Question[0], 0)
+}
+
+// resolveIteratively follows the referrals from the root servers until a server answers the question.
+// Aliases without records of the requested type are resolved from the root servers again and their records appended.
+func (r *Resolver) resolveIteratively(ctx context.Context, q dns.Question, depth int) (*dns.Msg, error) {
+	if depth > MAXITERATIONDEPTH {
+		return nil, fmt.Errorf("resolving %s %s: too many nested resolutions", q.Name, dns.TypeToString[q.Qtype])
+	}
+
+	zone := "."
+	servers := r.roots
+	for i := 0; i < MAXREFERRALS; i++ {
+		rsp, err := r.queryNameservers(ctx, q, zone, servers)
+		if err != nil {
+			return nil, err
+		}
+		if rsp.Rcode != dns.RcodeSuccess {
+			return rsp, nil
+		}
+
+		for _, rr := range rsp.Answer {
+			if rr.Header().Rrtype == q.Qtype {
+				return rsp, nil
+			}
+		}
+		if target := aliasTarget(rsp.Answer, q.Name); target != "" && q.Qtype != dns.TypeCNAME {
+			next, err := r.resolveIteratively(ctx, dns.Question{Name: target, Qtype: q.Qtype, Qclass: q.Qclass}, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			next = next.Copy()
+			next.Answer = append(append([]dns.RR{}, rsp.Answer...), next.Answer...)
+			return next, nil
+		}
+
+		child, hosts := referral(rsp, q.Name, zone)
+		if child == "" {
+			return rsp, nil
+		}
+		servers = r.referralServers(ctx, rsp, zone, hosts, depth)
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("resolving %s %s: no addresses found for the nameservers of %s", q.Name, dns.TypeToString[q.Qtype], child)
+		}
+		zone = child
+	}
+	return nil, fmt.Errorf("resolving %s %s: too many referrals", q.Name, dns.TypeToString[q.Qtype])
+}
+
+// queryNameservers sends the question to the nameservers of a zone without recursion, trying them in order
+// until one answers without a server failure or refusal. Every query is recorded in the trace of the context.
+func (r *Resolver) queryNameservers(ctx context.Context, q dns.Question, zone string, servers []nameserverAddress) (*dns.Msg, error) {
+	var failed *dns.Msg
+	var lastErr error
+	for _, ns := range servers {
+		msg := new(dns.Msg)
+		msg.SetQuestion(q.Name, q.Qtype)
+		msg.RecursionDesired = false
+
+		address := withPort(ns.address, r.nameserverPort)
+		start := time.Now()
+		rsp, err := r.exchangeServer(ctx, msg, address)
+		traceStep(ctx, newTraceStep(q, zone, ns, time.Since(start), rsp, err))
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("%s (%s): %w", ns.name, ns.address, err)
+			continue
+		}
+		if rsp.Rcode == dns.RcodeServerFailure || rsp.Rcode == dns.RcodeRefused {
+			failed = rsp
+			continue
+		}
+		return rsp, nil
+	}
+
+	if failed != nil {
+		return failed, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no nameservers for %s", zone)
+	}
+	return nil, lastErr
+}
+
+// newTraceStep returns the trace of a query sent to a nameserver of a zone
+func newTraceStep(q dns.Question, zone string, ns nameserverAddress, rtt time.Duration, rsp *dns.Msg, err error) types.TraceStep {
+	step := types.TraceStep{
+		Name:    q.Name,
+		Type:    dns.TypeToString[q.Qtype],
+		Zone:    zone,
+		Server:  ns.name,
+		Address: ns.address,
+		RTT:     rtt.String(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+		return step
+	}
+	step.Rcode = dns.RcodeToString[rsp.Rcode]
+	for _, rr := range rsp.Answer {
+		step.Answer = append(step.Answer, rr.String())
+	}
+	for _, rr := range rsp.Ns {
+		step.Authority = append(step.Authority, rr.String())
+	}
+	return step
+}
+
+// aliasTarget returns the target of the CNAME record of the name in the answer section, following chains of aliases
+func aliasTarget(answer []dns.RR, name string) string {
+	target := ""
+	for i := 0; i < len(answer); i++ {
+		for _, cname := range Records[*dns.CNAME](answer) {
+			if strings.EqualFold(cname.Hdr.Name, name) {
+				target, name = cname.Target, cname.Target
+			}
+		}
+	}
+	return target
+}
+
+// referral returns the zone delegated to by the NS records of the authority section and the names of its nameservers,
+// or an empty zone when the response isn't a referral to a zone below the current zone enclosing the name
+func referral(rsp *dns.Msg, name string, zone string) (string, []string) {
+	child := ""
+	hosts := make([]string, 0)
+	for _, ns := range Records[*dns.NS](rsp.Ns) {
+		owner := strings.ToLower(ns.Hdr.Name)
+		if !dns.IsSubDomain(owner, name) || !dns.IsSubDomain(zone, owner) || owner == strings.ToLower(zone) {
+			continue
+		}
+		if child != "" && owner != child {
+			continue
+		}
+		child = owner
+		hosts = append(hosts, strings.ToLower(ns.Ns))
+	}
+	return child, hosts
+}
+
+// referralServers returns the addresses of the nameservers of a referral from the glue records of the additional section.
+// Only glue within the zone of the server that sent the referral is trusted, and when there is none
+// the nameservers are resolved iteratively until one of them has addresses.
+func (r *Resolver) referralServers(ctx context.Context, rsp *dns.Msg, zone string, hosts []string, depth int) []nameserverAddress {
+	glue := make(map[string][]string)
+	for _, rr := range rsp.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(zone, name) {
+			continue
+		}
+		switch rr := rr.(type) {
+		case *dns.A:
+			glue[name] = append(glue[name], rr.A.String())
+		case *dns.AAAA:
+			glue[name] = append(glue[name], rr.AAAA.String())
+		}
+	}
+
+	servers := make([]nameserverAddress, 0)
+	for _, host := range hosts {
+		for _, address := range glue[host] {
+			servers = append(servers, nameserverAddress{name: host, address: address})
+		}
+	}
+	if len(servers) != 0 {
+		return servers
+	}
+
+	for _, host := range hosts {
+		rsp, err := r.resolveIteratively(ctx, dns.Question{Name: host, Qtype: dns.TypeA, Qclass: dns.ClassINET}, depth+1)
+		if err != nil {
+			r.logError(err)
+			continue
+		}
+		for _, a := range Records[*dns.A](rsp.Answer) {
+			servers = append(servers, nameserverAddress{name: host, address: a.A.String()})
+		}
+		if len(servers) != 0 {
+			return servers
+		}
+	}
+	return servers
+}
@@ -0,0 +1,199 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// delegating returns a handler referring names below the NS records of the zone text to their nameservers,
+// with the glue records of the nameservers within the delegated zone in the additional section.
+// Other names are answered like dnstest.Zone.
+func delegating(t *testing.T, zone string) dns.HandlerFunc {
+	t.Helper()
+
+	rrs := make([]dns.RR, 0)
+	zp := dns.NewZoneParser(strings.NewReader(zone), ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatalf("parsing zone: %v", err)
+	}
+	answer := dnstest.Zone(t, zone)
+
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		q := r.Question[0]
+		m := new(dns.Msg)
+		m.SetReply(r)
+		for _, ns := range Records[*dns.NS](rrs) {
+			if dns.IsSubDomain(ns.Hdr.Name, q.Name) {
+				m.Ns = append(m.Ns, ns)
+				if !dns.IsSubDomain(ns.Hdr.Name, ns.Ns) {
+					continue
+				}
+				for _, rr := range rrs {
+					if rr.Header().Rrtype == dns.TypeA && strings.EqualFold(rr.Header().Name, ns.Ns) {
+						m.Extra = append(m.Extra, rr)
+					}
+				}
+			}
+		}
+		if len(m.Ns) == 0 {
+			answer(w, r)
+			return
+		}
+		w.WriteMsg(m)
+	}
+}
+
+// startHierarchy starts a root server on 127.0.0.1, a server for the test TLD on 127.0.0.2 and an authoritative server
+// for example.test and glueless.test on 127.0.0.3, all on the same port, and returns an iterative resolver using them
+func startHierarchy(t *testing.T) *Resolver {
+	root := dnstest.Start(t, "127.0.0.1:0", delegating(t, `
+test. 86400 IN NS ns.test.
+ns.test. 86400 IN A 127.0.0.2
+`))
+	_, port, err := net.SplitHostPort(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dnstest.Start(t, "127.0.0.2:"+port, delegating(t, `
+example.test. 3600 IN NS ns.example.test.
+ns.example.test. 3600 IN A 127.0.0.3
+glueless.test. 3600 IN NS ns.example.test.
+`))
+	dnstest.Start(t, "127.0.0.3:"+port, dnstest.Zone(t, `
+example.test. 3600 IN SOA ns.example.test. hostmaster.example.test. 1 7200 3600 1209600 300
+ns.example.test. 3600 IN A 127.0.0.3
+www.example.test. 300 IN A 192.0.2.1
+alias.example.test. 300 IN CNAME www.glueless.test.
+www.glueless.test. 300 IN A 192.0.2.2
+`))
+
+	hints := filepath.Join(t.TempDir(), "named.root")
+	if err := ioutil.WriteFile(hints, []byte(". 3600000 NS a.root.test.\na.root.test. 3600000 A 127.0.0.1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := NewIterativeResolver(types.Options{RootHints: hints, Port: p, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver
+}
+
+func TestIterativeResolver(t *testing.T) {
+	resolver := startHierarchy(t)
+
+	tests := []struct {
+		name       string
+		domain     string
+		wantAnswer []string
+		wantErr    error
+		wantZones  []string
+	}{
+		{
+			name:       "referrals with glue",
+			domain:     "www.example.test.",
+			wantAnswer: []string{"192.0.2.1"},
+			wantZones:  []string{".", "test.", "example.test."},
+		},
+		{
+			name:       "referral without glue",
+			domain:     "www.glueless.test.",
+			wantAnswer: []string{"192.0.2.2"},
+			wantZones:  []string{".", "test.", ".", "test.", "example.test.", "glueless.test."},
+		},
+		{
+			name:       "alias restarted from the root",
+			domain:     "alias.example.test.",
+			wantAnswer: []string{"192.0.2.2"},
+			wantZones:  []string{".", "test.", "example.test.", ".", "test.", ".", "test.", "example.test.", "glueless.test."},
+		},
+		{
+			name:      "nxdomain",
+			domain:    "missing.example.test.",
+			wantErr:   ErrNXDomain,
+			wantZones: []string{".", "test.", "example.test."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := WithTrace(context.Background())
+			res, err := resolver.LookupA(ctx, tt.domain)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("LookupA() error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("LookupA() error = %v", err)
+			}
+
+			answer := make([]string, 0)
+			for _, a := range res {
+				answer = append(answer, a.A.String())
+			}
+			if tt.wantAnswer == nil {
+				tt.wantAnswer = []string{}
+			}
+			if !reflect.DeepEqual(answer, tt.wantAnswer) {
+				t.Errorf("LookupA() = %v, want %v", answer, tt.wantAnswer)
+			}
+
+			steps := TraceFromContext(ctx)
+			zones := make([]string, 0)
+			for _, step := range steps {
+				zones = append(zones, step.Zone)
+				if step.Error != "" || step.Type != "A" {
+					t.Errorf("trace step %+v, want an A query without error", step)
+				}
+			}
+			if !reflect.DeepEqual(zones, tt.wantZones) {
+				t.Errorf("trace zones = %v, want %v", zones, tt.wantZones)
+			}
+			if first := steps[0]; first.Server != "a.root.test." || first.Address != "127.0.0.1" || first.Name != tt.domain {
+				t.Errorf("first trace step = %+v, want %s sent to a.root.test. (127.0.0.1)", first, tt.domain)
+			}
+			if last := steps[len(steps)-1]; last.Address != "127.0.0.3" || last.Server != "ns.example.test." {
+				t.Errorf("last trace step = %+v, want a query sent to ns.example.test. (127.0.0.3)", last)
+			}
+		})
+	}
+}
+
+func TestParseRootHints(t *testing.T) {
+	roots, err := parseRootHints(`
+. 3600000 NS A.ROOT.TEST.
+A.ROOT.TEST. 3600000 AAAA ::1
+A.ROOT.TEST. 3600000 A 127.0.0.1
+. 3600000 NS b.root.test.
+b.root.test. 3600000 A 127.0.0.2
+c.root.test. 3600000 A 127.0.0.4
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []nameserverAddress{{name: "a.root.test.", address: "127.0.0.1"}, {name: "b.root.test.", address: "127.0.0.2"}, {name: "a.root.test.", address: "::1"}}
+	if !reflect.DeepEqual(roots, want) {
+		t.Errorf("parseRootHints() = %v, want %v", roots, want)
+	}
+
+	if _, err := parseRootHints(". 3600000 NS a.root.test.\n"); err == nil {
+		t.Errorf("parseRootHints() without addresses didn't fail")
+	}
+}
@@ -0,0 +1,56 @@
+package dnsutil
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// counters counts the queries, retries and cache lookups made
+type counters struct {
+	queries     uint64
+	retries     uint64
+	cacheHits   uint64
+	cacheMisses uint64
+}
+
+// metadata returns a snapshot of the counters
+func (c *counters) metadata() types.Metadata {
+	return types.Metadata{
+		Queries:     atomic.LoadUint64(&c.queries),
+		Retries:     atomic.LoadUint64(&c.retries),
+		CacheHits:   atomic.LoadUint64(&c.cacheHits),
+		CacheMisses: atomic.LoadUint64(&c.cacheMisses),
+	}
+}
+
+// countersKey is the context key of the counters of a context
+type countersKey struct{}
+
+// WithMetadata returns a context counting the queries, retries and cache lookups made with it,
+// so that a resolver shared by several lookups can report the metadata of each of them
+func WithMetadata(ctx context.Context) context.Context {
+	return context.WithValue(ctx, countersKey{}, &counters{})
+}
+
+// MetadataFromContext returns the metadata counted for a context returned by WithMetadata
+func MetadataFromContext(ctx context.Context) types.Metadata {
+	if c, ok := ctx.Value(countersKey{}).(*counters); ok {
+		return c.metadata()
+	}
+	return types.Metadata{}
+}
+
+// count increments a counter of the resolver and of the context
+func (r *Resolver) count(ctx context.Context, counter func(c *counters) *uint64) {
+	atomic.AddUint64(counter(&r.counters), 1)
+	if c, ok := ctx.Value(countersKey{}).(*counters); ok {
+		atomic.AddUint64(counter(c), 1)
+	}
+}
+
+// Metadata returns the number of queries sent and retried by the resolver and the cache hits and misses
+func (r *Resolver) Metadata() types.Metadata {
+	return r.counters.metadata()
+}
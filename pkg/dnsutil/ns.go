@@ -0,0 +1,82 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/marc-barry/domaininfo/pkg/cache"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// ZoneOf returns the zone a domain belongs to, which is the owner of the SOA record in the answer to a SOA query
+// or in the authority section of a negative answer. Aliases are looked up from their parent name instead.
+// Failed answers such as SERVFAIL or REFUSED are returned as errors rather than walked past to the parent.
+func ZoneOf(ctx context.Context, resolver *Resolver, domain string) (string, error) {
+	name := dns.Fqdn(domain)
+	for {
+		a, err := resolver.Lookup(ctx, name, dns.TypeSOA)
+		if err != nil {
+			return "", err
+		}
+		if err := firstFailure([]error{a.Err()}); err != nil {
+			return "", err
+		}
+		if len(Records[*dns.CNAME](a.Answer)) == 0 {
+			rrs := append(append([]dns.RR{}, a.Answer...), a.Authority...)
+			if soas := Records[*dns.SOA](rrs); len(soas) != 0 {
+				return strings.ToLower(soas[0].Hdr.Name), nil
+			}
+		}
+
+		i, end := dns.NextLabel(name, 0)
+		if end || name == "." {
+			return "", fmt.Errorf("no zone found for %s", domain)
+		}
+		name = name[i:]
+	}
+}
+
+// NSInfos returns the nameservers of the zone of a domain with the ASN info of their IP addresses and the ASNs and countries hosting them
+func NSInfos(ctx context.Context, resolver *Resolver, store *cache.Store, domain string) (types.NSInfo, error) {
+	info := types.NSInfo{Nameservers: make([]types.NameserverInfo, 0), ASNs: make([]string, 0), Countries: make([]string, 0)}
+
+	zone, err := ZoneOf(ctx, resolver, domain)
+	if err != nil {
+		return info, err
+	}
+	info.Zone = zone
+
+	res, err := resolver.LookupNS(ctx, zone)
+	if err != nil {
+		return info, err
+	}
+	hosts := make([]string, 0)
+	for _, r := range res {
+		hosts = append(hosts, strings.ToLower(r.Ns))
+	}
+	sort.Strings(hosts)
+
+	infos, asns, err := hostsInfos(ctx, resolver, store, hosts)
+	info.ASNs = asns
+
+	countries := make(map[string]bool)
+	for i, host := range hosts {
+		info.Nameservers = append(info.Nameservers, types.NameserverInfo{Name: host, IPv4AddressInfo: infos[i].ipv4Info, IPv6AddressInfo: infos[i].ipv6Info})
+		for _, addressInfo := range []map[string][]types.ASNInfo{infos[i].ipv4Info, infos[i].ipv6Info} {
+			for _, asnInfos := range addressInfo {
+				for _, asnInfo := range asnInfos {
+					countries[asnInfo.Country] = true
+				}
+			}
+		}
+	}
+	for country := range countries {
+		info.Countries = append(info.Countries, country)
+	}
+	sort.Strings(info.Countries)
+
+	return info, err
+}
@@ -0,0 +1,86 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// exampleZone contains the example.com zone with its nameservers and the Cymru origins of their addresses
+const exampleZone = `
+example.com. 300 IN SOA ns1.example.com. hostmaster.example.com. 1 7200 3600 1209600 300
+example.com. 300 IN NS ns2.example.com.
+example.com. 300 IN NS NS1.example.com.
+www.example.com. 300 IN CNAME cdn.example.net.
+ns1.example.com. 300 IN A 192.0.2.1
+ns2.example.com. 300 IN A 198.51.100.1
+ns2.example.com. 300 IN AAAA 2001:db8::53
+1.2.0.192.origin.asn.cymru.com. 300 IN TXT "64496 | 192.0.2.0/24 | US | arin | 2010-07-14"
+1.100.51.198.origin.asn.cymru.com. 300 IN TXT "64497 | 198.51.100.0/24 | GB | ripencc | 2019-12-18"
+3.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com. 300 IN TXT "64497 | 2001:db8::/32 | GB | ripencc | 2019-12-18"
+`
+
+func TestZoneOf(t *testing.T) {
+	resolver := testResolver(dnstest.Start(t, "127.0.0.1:0", dnstest.Zone(t, exampleZone)))
+
+	tests := []struct {
+		name   string
+		domain string
+	}{
+		{"apex", "example.com"},
+		{"alias", "www.example.com"},
+		{"negative answer", "missing.example.com"},
+		{"empty non-terminal", "a.b.example.com."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone, err := ZoneOf(context.Background(), resolver, tt.domain)
+			if err != nil {
+				t.Fatalf("ZoneOf(%s) error = %v", tt.domain, err)
+			}
+			if zone != "example.com." {
+				t.Errorf("ZoneOf(%s) = %q, want example.com.", tt.domain, zone)
+			}
+		})
+	}
+}
+
+func TestZoneOfServerFailure(t *testing.T) {
+	resolver := testResolver(dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeRefused)))
+
+	zone, err := ZoneOf(context.Background(), resolver, "www.example.com")
+	var rerr *RcodeError
+	if !errors.As(err, &rerr) || rerr.Rcode != dns.RcodeRefused {
+		t.Errorf("ZoneOf() = %q, %v, want the REFUSED answer", zone, err)
+	}
+}
+
+func TestNSInfos(t *testing.T) {
+	resolver := testResolver(dnstest.Start(t, "127.0.0.1:0", dnstest.Zone(t, exampleZone)))
+
+	info, err := NSInfos(context.Background(), resolver, nil, "www.example.com")
+	if err != nil {
+		t.Fatalf("NSInfos() error = %v", err)
+	}
+
+	us := types.ASNInfo{ASN: "64496", AddressBlock: "192.0.2.0/24", Country: "US", InternetRegistry: "arin", Date: "2010-07-14"}
+	gb := types.ASNInfo{ASN: "64497", AddressBlock: "198.51.100.0/24", Country: "GB", InternetRegistry: "ripencc", Date: "2019-12-18"}
+	gb6 := types.ASNInfo{ASN: "64497", AddressBlock: "2001:db8::/32", Country: "GB", InternetRegistry: "ripencc", Date: "2019-12-18"}
+	want := types.NSInfo{
+		Zone: "example.com.",
+		Nameservers: []types.NameserverInfo{
+			{Name: "ns1.example.com.", IPv4AddressInfo: map[string][]types.ASNInfo{"192.0.2.1": {us}}, IPv6AddressInfo: map[string][]types.ASNInfo{}},
+			{Name: "ns2.example.com.", IPv4AddressInfo: map[string][]types.ASNInfo{"198.51.100.1": {gb}}, IPv6AddressInfo: map[string][]types.ASNInfo{"2001:db8::53": {gb6}}},
+		},
+		ASNs:      []string{"64496", "64497"},
+		Countries: []string{"GB", "US"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("NSInfos() = %+v, want %+v", info, want)
+	}
+}
@@ -0,0 +1,67 @@
+package dnsutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// NewResolverFromConfig constructs a new DNS resolver from a resolv.conf(5) file.
+// The nameservers, search list, ndots, timeout, attempts and rotate settings are honored.
+// A non-zero timeout or non-negative retries in the options overrides the value from the file,
+// and the rotate option selects the round-robin strategy when no strategy is given.
+func NewResolverFromConfig(path string, opts types.Options) (*Resolver, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := dns.ClientConfigFromReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Servers) == 0 {
+		return nil, fmt.Errorf("no nameservers found in %s", path)
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Duration(config.Timeout) * time.Second
+	}
+	if opts.Retries < 0 {
+		opts.Retries = config.Attempts - 1
+	}
+
+	if opts.Strategy == "" && hasRotateOption(b) {
+		opts.Strategy = types.STRATEGYROUNDROBIN
+	}
+
+	r := newResolver(config.Servers, opts)
+	r.search = config.Search
+	r.ndots = config.Ndots
+	return r, nil
+}
+
+// hasRotateOption checks if the resolv.conf(5) contents set the rotate option
+func hasRotateOption(b []byte) bool {
+	rotate := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		f := strings.Fields(scanner.Text())
+		if len(f) < 1 || f[0] != "options" {
+			continue
+		}
+		for _, s := range f[1:] {
+			if s == "rotate" {
+				rotate = true
+			}
+		}
+	}
+	return rotate
+}
@@ -0,0 +1,146 @@
+package dnsutil
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// writeResolvConf writes the resolv.conf contents to a temporary file and returns its path
+func writeResolvConf(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	if err := ioutil.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewResolverFromConfig(t *testing.T) {
+	const conf = `# generated
+nameserver 10.0.0.2
+nameserver 2001:db8::53
+search corp.example.com example.com
+options ndots:2 timeout:3 attempts:4 rotate
+`
+	tests := []struct {
+		name         string
+		conf         string
+		opts         types.Options
+		wantServers  []string
+		wantSearch   []string
+		wantNdots    int
+		wantTimeout  time.Duration
+		wantAttempts int
+		wantStrategy string
+	}{
+		{
+			name:         "settings from the file",
+			conf:         conf,
+			opts:         types.Options{Retries: types.DEFAULTRETRIES},
+			wantServers:  []string{"10.0.0.2:53", "[2001:db8::53]:53"},
+			wantSearch:   []string{"corp.example.com", "example.com"},
+			wantNdots:    2,
+			wantTimeout:  3 * time.Second,
+			wantAttempts: 4,
+			wantStrategy: types.STRATEGYROUNDROBIN,
+		},
+		{
+			name:         "options override the file",
+			conf:         conf,
+			opts:         types.Options{Port: 5353, Timeout: time.Second, Retries: 1, Strategy: types.STRATEGYRACE},
+			wantServers:  []string{"10.0.0.2:5353", "[2001:db8::53]:5353"},
+			wantSearch:   []string{"corp.example.com", "example.com"},
+			wantNdots:    2,
+			wantTimeout:  time.Second,
+			wantAttempts: 2,
+			wantStrategy: types.STRATEGYRACE,
+		},
+		{
+			name:         "zero retries override attempts",
+			conf:         conf,
+			opts:         types.Options{Retries: 0},
+			wantServers:  []string{"10.0.0.2:53", "[2001:db8::53]:53"},
+			wantSearch:   []string{"corp.example.com", "example.com"},
+			wantNdots:    2,
+			wantTimeout:  3 * time.Second,
+			wantAttempts: 1,
+			wantStrategy: types.STRATEGYROUNDROBIN,
+		},
+		{
+			name:         "defaults",
+			conf:         "nameserver 10.0.0.2\n",
+			opts:         types.Options{Retries: types.DEFAULTRETRIES},
+			wantServers:  []string{"10.0.0.2:53"},
+			wantSearch:   []string{},
+			wantNdots:    1,
+			wantTimeout:  5 * time.Second,
+			wantAttempts: 2,
+			wantStrategy: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewResolverFromConfig(writeResolvConf(t, tt.conf), tt.opts)
+			if err != nil {
+				t.Fatalf("NewResolverFromConfig() error = %v", err)
+			}
+			servers := make([]string, 0)
+			for _, u := range r.upstreams {
+				servers = append(servers, u.address)
+			}
+			if !reflect.DeepEqual(servers, tt.wantServers) {
+				t.Errorf("servers = %v, want %v", servers, tt.wantServers)
+			}
+			if !reflect.DeepEqual(r.search, tt.wantSearch) {
+				t.Errorf("search = %v, want %v", r.search, tt.wantSearch)
+			}
+			if r.ndots != tt.wantNdots {
+				t.Errorf("ndots = %d, want %d", r.ndots, tt.wantNdots)
+			}
+			if r.timeout != tt.wantTimeout {
+				t.Errorf("timeout = %s, want %s", r.timeout, tt.wantTimeout)
+			}
+			if r.retry.MaxAttempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", r.retry.MaxAttempts, tt.wantAttempts)
+			}
+			if r.strategy != tt.wantStrategy {
+				t.Errorf("strategy = %q, want %q", r.strategy, tt.wantStrategy)
+			}
+		})
+	}
+}
+
+func TestNewResolverFromConfigErrors(t *testing.T) {
+	if _, err := NewResolverFromConfig(filepath.Join(t.TempDir(), "missing"), types.Options{}); err == nil {
+		t.Errorf("NewResolverFromConfig() of a missing file didn't fail")
+	}
+	if _, err := NewResolverFromConfig(writeResolvConf(t, "search example.com\n"), types.Options{}); err == nil {
+		t.Errorf("NewResolverFromConfig() without nameservers didn't fail")
+	}
+}
+
+func TestNameList(t *testing.T) {
+	r, err := NewResolverFromConfig(writeResolvConf(t, "nameserver 10.0.0.2\nsearch corp.example.com\noptions ndots:2\n"), types.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"host", []string{"host.corp.example.com.", "host."}},
+		{"host.a", []string{"host.a.corp.example.com.", "host.a."}},
+		{"www.example.com", []string{"www.example.com.", "www.example.com.corp.example.com."}},
+		{"www.example.com.", []string{"www.example.com."}},
+	}
+	for _, tt := range tests {
+		if got := r.NameList(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("NameList(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,146 +1,351 @@
 package dnsutil
 
 import (
-	"fmt"
-
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
 	"github.com/miekg/dns"
 )
 
 // Resolver represents a DNS resolver that can be used to lookup DNS records
 type Resolver struct {
-	c       *dns.Client
-	address string
+	timeout        time.Duration
+	tlsConfig      *tls.Config
+	http           *http.Client
+	httpMethod     string
+	udpSize        uint16
+	debug          bool
+	upstreams      []*upstream
+	strategy       string
+	next           uint32
+	retry          RetryPolicy
+	cache          *answerCache
+	concurrency    int
+	inflight       chan struct{}
+	counters       counters
+	search         []string
+	ndots          int
+	nameserverPort int
+	roots          []nameserverAddress
 }
 
 // NewResolver constructs a new DNS resolver with an underlying DNS client
 func NewResolver(address string) *Resolver {
+	return NewResolverWithOptions(types.Options{Resolvers: []string{address}})
+}
+
+// NewResolverWithOptions constructs a new DNS resolver honoring the resolvers, strategy, port, timeout and retries options
+func NewResolverWithOptions(opts types.Options) *Resolver {
+	return newResolver(opts.Resolvers, opts)
+}
+
+// newResolver constructs a new DNS resolver for a list of upstream addresses
+func newResolver(addresses []string, opts types.Options) *Resolver {
+	port := opts.Port
+	if port == 0 {
+		port = types.DEFAULTPORT
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = types.DEFAULTTIMEOUT
+	}
+
 	r := new(Resolver)
-	r.c = &dns.Client{}
-	r.address = address
+	r.timeout = timeout
+	r.tlsConfig = newTLSConfig(opts.TLSServerName, opts.TLSPins)
+	r.http = &http.Client{Timeout: timeout}
+	r.httpMethod = opts.DoHMethod
+	r.udpSize = opts.UDPSize
+	r.debug = opts.Debug
+	for _, address := range addresses {
+		r.upstreams = append(r.upstreams, &upstream{address: withPort(address, port)})
+	}
+	r.strategy = opts.Strategy
+	r.retry = NewRetryPolicy(opts)
+	r.concurrency = opts.Concurrency
+	if r.concurrency < 1 {
+		r.concurrency = types.DEFAULTCONCURRENCY
+	}
+	r.inflight = make(chan struct{}, r.concurrency)
+	if opts.DNSCache {
+		r.cache = newAnswerCache()
+	}
+	r.ndots = 1
+	r.nameserverPort = types.DEFAULTPORT
 	return r
 }
 
-// LookupA looks up A records for a domain
-func (r *Resolver) LookupA(name string) ([]*dns.A, error) {
-	var rrs []*dns.A
+// withPort appends the port to the address if the address doesn't already have one
+func withPort(address string, port int) string {
+	if isHTTPSAddress(address) {
+		return address
+	}
+	if isTLSAddress(address) {
+		return TLSSCHEME + withPort(strings.TrimPrefix(address, TLSSCHEME), types.DEFAULTTLSPORT)
+	}
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(strings.Trim(address, "[]"), strconv.Itoa(port))
+}
 
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), dns.TypeA)
+// NameList returns the names to query for a name according to the search list and ndots setting
+func (r *Resolver) NameList(name string) []string {
+	config := dns.ClientConfig{Search: r.search, Ndots: r.ndots}
+	return config.NameList(name)
+}
 
-	rsp, _, err := r.c.Exchange(msg, r.address)
-	if err != nil {
-		return nil, err
+// exchange sends the message to the upstream resolvers, retrying according to the retry policy.
+// When every upstream answered with a server failure or refusal the last response is returned.
+// Iterative resolvers resolve the question of the message from the root servers instead.
+func (r *Resolver) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	if r.roots != nil {
+		return r.iterate(ctx, msg)
 	}
 
-	if rsp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("lookup code %s", dns.RcodeToString[rsp.Rcode])
+	rsp, err := r.retrying(ctx, msg, r.exchangeUpstreams)
+	if err == nil {
+		return rsp, nil
 	}
 
-	for _, rr := range rsp.Answer {
-		if a, ok := rr.(*dns.A); ok {
-			rrs = append(rrs, a)
+	var uerr *UpstreamError
+	if errors.As(err, &uerr) {
+		if rsp := uerr.response(); rsp != nil {
+			return rsp, nil
 		}
 	}
-
-	return rrs, nil
+	return nil, err
 }
 
-// LookupAAAA looks up AAAA records for a domain
-func (r *Resolver) LookupAAAA(name string) ([]*dns.AAAA, error) {
-	var rrs []*dns.AAAA
+// retrying sends the message using the send function, retrying according to the retry policy
+func (r *Resolver) retrying(ctx context.Context, msg *dns.Msg, send func(context.Context, *dns.Msg) (*dns.Msg, error)) (*dns.Msg, error) {
+	if r.udpSize != 0 && msg.IsEdns0() == nil {
+		msg.SetEdns0(r.udpSize, false)
+	}
 
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), dns.TypeAAAA)
+	for attempt := 0; ; attempt++ {
+		rsp, err := send(ctx, msg)
+		if err == nil {
+			return rsp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt+1 >= r.retry.MaxAttempts || !r.retry.Retryable(err) {
+			return nil, err
+		}
 
-	rsp, _, err := r.c.Exchange(msg, r.address)
-	if err != nil {
-		return nil, err
-	}
+		delay := r.retry.Backoff(attempt)
+		r.count(ctx, func(c *counters) *uint64 { return &c.retries })
+		r.logf("%s %s failed, retrying in %s: %v", msg.Question[0].Name, dns.TypeToString[msg.Question[0].Qtype], delay, err)
 
-	if rsp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("lookup code %s", dns.RcodeToString[rsp.Rcode])
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
 	}
+}
 
-	for _, rr := range rsp.Answer {
-		if a, ok := rr.(*dns.AAAA); ok {
-			rrs = append(rrs, a)
+// exchangeServer sends the message to a single server, retrying server failures and refusals according to the retry policy
+// like other failures. The last response is returned when the server still fails after the retries.
+func (r *Resolver) exchangeServer(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, error) {
+	rsp, err := r.retrying(ctx, msg, func(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+		rsp, err := r.exchangeWith(ctx, msg, address)
+		if err == nil && (rsp.Rcode == dns.RcodeServerFailure || rsp.Rcode == dns.RcodeRefused) {
+			return nil, &rcodeFailure{rsp: rsp}
 		}
-	}
+		return rsp, err
+	})
 
-	return rrs, nil
+	var rerr *rcodeFailure
+	if errors.As(err, &rerr) {
+		return rerr.rsp, nil
+	}
+	return rsp, err
 }
 
-// LookupCAA looks up CAA records for a domain
-func (r *Resolver) LookupCAA(name string) ([]*dns.CAA, error) {
-	var rrs []*dns.CAA
+// exchangeWith sends the message to an upstream resolver over UDP, falling back to TCP when the response is truncated.
+// DNS-over-HTTPS and DNS-over-TLS resolvers are sent the message over HTTPS and TLS instead.
+// At most the concurrency of the resolver is sent at once, whichever lookups the messages are for.
+func (r *Resolver) exchangeWith(ctx context.Context, msg *dns.Msg, address string) (*dns.Msg, error) {
+	select {
+	case r.inflight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-r.inflight }()
 
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), dns.TypeCAA)
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
 
-	rsp, _, err := r.c.Exchange(msg, r.address)
-	if err != nil {
-		return nil, err
+	r.count(ctx, func(c *counters) *uint64 { return &c.queries })
+	if isHTTPSAddress(address) {
+		rsp, rtt, err := r.exchangeHTTPS(ctx, msg, address)
+		r.logQuery(msg, address, "https", rtt, err)
+		return rsp, err
 	}
-
-	if rsp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("lookup code %s", dns.RcodeToString[rsp.Rcode])
+	if isTLSAddress(address) {
+		rsp, rtt, err := r.exchangeContext(ctx, "tcp-tls", msg, strings.TrimPrefix(address, TLSSCHEME))
+		r.logQuery(msg, address, "tls", rtt, err)
+		return rsp, err
 	}
 
-	for _, rr := range rsp.Answer {
-		if a, ok := rr.(*dns.CAA); ok {
-			rrs = append(rrs, a)
-		}
+	rsp, rtt, err := r.exchangeContext(ctx, "udp", msg, address)
+	if err == nil && rsp.Truncated {
+		r.logf("%s %s to %s over udp was truncated, retrying over tcp", msg.Question[0].Name, dns.TypeToString[msg.Question[0].Qtype], address)
+		rsp, rtt, err = r.exchangeContext(ctx, "tcp", msg, address)
+		r.logQuery(msg, address, "tcp", rtt, err)
+		return rsp, err
 	}
-
-	return rrs, nil
+	r.logQuery(msg, address, "udp", rtt, err)
+	return rsp, err
 }
 
-// LookupCNAME looks up CNAME records for a domain
-func (r *Resolver) LookupCNAME(name string) ([]*dns.CNAME, error) {
-	var rrs []*dns.CNAME
+// exchangeContext sends the message over the network using a new DNS client, returning as soon as the context is done.
+// A new client is used for every exchange because the DNS client's ExchangeContext isn't safe for concurrent use.
+func (r *Resolver) exchangeContext(ctx context.Context, network string, msg *dns.Msg, address string) (*dns.Msg, time.Duration, error) {
+	type result struct {
+		rsp *dns.Msg
+		rtt time.Duration
+		err error
+	}
 
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), dns.TypeCNAME)
+	c := &dns.Client{Net: network, Timeout: r.timeout}
+	if network == "tcp-tls" {
+		c.TLSConfig = r.tlsConfig
+	}
 
-	rsp, _, err := r.c.Exchange(msg, r.address)
-	if err != nil {
-		return nil, err
+	results := make(chan result, 1)
+	go func() {
+		rsp, rtt, err := c.ExchangeContext(ctx, msg, address)
+		results <- result{rsp: rsp, rtt: rtt, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	case res := <-results:
+		return res.rsp, res.rtt, res.err
 	}
+}
 
-	if rsp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("lookup code %s", dns.RcodeToString[rsp.Rcode])
+// logQuery logs the outcome of a query and the transport used when debugging is enabled
+func (r *Resolver) logQuery(msg *dns.Msg, address string, transport string, rtt time.Duration, err error) {
+	if err != nil {
+		r.logf("%s %s to %s over %s failed: %v", msg.Question[0].Name, dns.TypeToString[msg.Question[0].Qtype], address, transport, err)
+		return
 	}
+	r.logf("%s %s to %s over %s took %s", msg.Question[0].Name, dns.TypeToString[msg.Question[0].Qtype], address, transport, rtt)
+}
 
-	for _, rr := range rsp.Answer {
-		if a, ok := rr.(*dns.CNAME); ok {
-			rrs = append(rrs, a)
-		}
+// logError logs a non-fatal error when debugging is enabled
+func (r *Resolver) logError(err error) {
+	if err != nil {
+		r.logf("%v", err)
 	}
+}
 
-	return rrs, nil
+// logf logs a debug message when debugging is enabled
+func (r *Resolver) logf(format string, v ...interface{}) {
+	if r.debug {
+		log.Printf(format, v...)
+	}
 }
 
-// LookupTXT looks up TXT records for a domain
-func (r *Resolver) LookupTXT(name string) ([]*dns.TXT, error) {
-	var rrs []*dns.TXT
+// Lookup looks up records of any type for a domain and returns the full response, whatever its rcode.
+// When caching is enabled, answers are served from the cache until their TTL expires.
+func (r *Resolver) Lookup(ctx context.Context, name string, qtype uint16) (*Answer, error) {
+	if r.cache != nil {
+		if a, ok := r.cache.get(name, qtype); ok {
+			r.count(ctx, func(c *counters) *uint64 { return &c.cacheHits })
+			return a, nil
+		}
+		r.count(ctx, func(c *counters) *uint64 { return &c.cacheMisses })
+	}
 
 	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(name), dns.TypeTXT)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
 
-	rsp, _, err := r.c.Exchange(msg, r.address)
+	rsp, err := r.exchange(ctx, msg)
 	if err != nil {
 		return nil, err
 	}
 
-	if rsp.Rcode != dns.RcodeSuccess {
-		return nil, fmt.Errorf("lookup code %s", dns.RcodeToString[rsp.Rcode])
+	a := newAnswer(msg.Question[0], rsp)
+	if r.cache != nil {
+		r.cache.put(a)
 	}
+	return a, nil
+}
 
-	for _, rr := range rsp.Answer {
-		if a, ok := rr.(*dns.TXT); ok {
-			rrs = append(rrs, a)
-		}
+// LookupFrom looks up records of any type for a domain directly from a server, such as an authoritative nameserver,
+// bypassing the upstream resolvers and the cache. Servers given without a port use port 53,
+// or the port from the options for iterative resolvers.
+// Recursion isn't requested and the full response is returned, whatever its rcode.
+func (r *Resolver) LookupFrom(ctx context.Context, server string, name string, qtype uint16) (*Answer, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = false
+
+	rsp, err := r.exchangeServer(ctx, msg, withPort(server, r.nameserverPort))
+	if err != nil {
+		return nil, err
 	}
+	return newAnswer(msg.Question[0], rsp), nil
+}
 
-	return rrs, nil
+// LookupA looks up A records for a domain
+func (r *Resolver) LookupA(ctx context.Context, name string) ([]*dns.A, error) {
+	return LookupRecords[*dns.A](ctx, r, name, dns.TypeA)
+}
+
+// LookupAAAA looks up AAAA records for a domain
+func (r *Resolver) LookupAAAA(ctx context.Context, name string) ([]*dns.AAAA, error) {
+	return LookupRecords[*dns.AAAA](ctx, r, name, dns.TypeAAAA)
+}
+
+// LookupCAA looks up CAA records for a domain
+func (r *Resolver) LookupCAA(ctx context.Context, name string) ([]*dns.CAA, error) {
+	return LookupRecords[*dns.CAA](ctx, r, name, dns.TypeCAA)
+}
+
+// LookupCNAME looks up CNAME records for a domain
+func (r *Resolver) LookupCNAME(ctx context.Context, name string) ([]*dns.CNAME, error) {
+	return LookupRecords[*dns.CNAME](ctx, r, name, dns.TypeCNAME)
+}
+
+// LookupMX looks up MX records for a domain
+func (r *Resolver) LookupMX(ctx context.Context, name string) ([]*dns.MX, error) {
+	return LookupRecords[*dns.MX](ctx, r, name, dns.TypeMX)
+}
+
+// LookupNS looks up NS records for a domain
+func (r *Resolver) LookupNS(ctx context.Context, name string) ([]*dns.NS, error) {
+	return LookupRecords[*dns.NS](ctx, r, name, dns.TypeNS)
+}
+
+// LookupPTR looks up PTR records for a reverse domain
+func (r *Resolver) LookupPTR(ctx context.Context, name string) ([]*dns.PTR, error) {
+	return LookupRecords[*dns.PTR](ctx, r, name, dns.TypePTR)
+}
+
+// LookupSOA looks up SOA records for a domain
+func (r *Resolver) LookupSOA(ctx context.Context, name string) ([]*dns.SOA, error) {
+	return LookupRecords[*dns.SOA](ctx, r, name, dns.TypeSOA)
+}
+
+// LookupTXT looks up TXT records for a domain
+func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]*dns.TXT, error) {
+	return LookupRecords[*dns.TXT](ctx, r, name, dns.TypeTXT)
 }
@@ -0,0 +1,90 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestTruncatedRetriedOverTCP(t *testing.T) {
+	tests := []struct {
+		name     string
+		udpSize  uint16
+		wantEdns bool
+	}{
+		{"edns0", types.DEFAULTUDPSIZE, true},
+		{"without edns0", 0, false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			networks := make([]string, 0)
+			address := dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+				network := "tcp"
+				if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+					network = "udp"
+				}
+				mu.Lock()
+				networks = append(networks, network)
+				mu.Unlock()
+
+				opt := r.IsEdns0()
+				if (opt != nil) != tt.wantEdns {
+					t.Errorf("query over %s has EDNS0 %v, want EDNS0 %t", network, opt, tt.wantEdns)
+				} else if opt != nil && opt.UDPSize() != tt.udpSize {
+					t.Errorf("EDNS0 UDP size = %d, want %d", opt.UDPSize(), tt.udpSize)
+				}
+
+				m := new(dns.Msg)
+				m.SetReply(r)
+				for i := 0; i < 64; i++ {
+					m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.IPv4(192, 0, 2, byte(i))})
+				}
+				if network == "udp" {
+					m.Truncate(512)
+				}
+				w.WriteMsg(m)
+			})
+
+			resolver := NewResolverWithOptions(types.Options{Resolvers: []string{address}, Timeout: time.Second, UDPSize: tt.udpSize})
+			res, err := resolver.LookupA(context.Background(), "many.example.com.")
+			if err != nil {
+				t.Fatalf("LookupA() error = %v", err)
+			}
+			if len(res) != 64 {
+				t.Errorf("LookupA() returned %d records, want 64", len(res))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(networks) != 2 || networks[0] != "udp" || networks[1] != "tcp" {
+				t.Errorf("queries sent over %v, want [udp tcp]", networks)
+			}
+		})
+	}
+}
+
+func TestLookupCancelled(t *testing.T) {
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{silent(t)}, Timeout: 5 * time.Second, Retries: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := resolver.Lookup(ctx, "www.example.com.", dns.TypeA)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Lookup() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Lookup() returned after %s, want it to return once cancelled", elapsed)
+	}
+	if health := resolver.Health(); health[0].Failures != 0 {
+		t.Errorf("cancelled query counted as a failure: %+v", health[0])
+	}
+}
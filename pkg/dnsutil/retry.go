@@ -0,0 +1,106 @@
+package dnsutil
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// RetryPolicy decides which failed queries are retried and how long to wait between attempts
+type RetryPolicy struct {
+	MaxAttempts     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	RetryableRcodes []int
+}
+
+// ParseRcodes parses a comma separated list of rcode names such as SERVFAIL,REFUSED
+func ParseRcodes(s string) ([]int, error) {
+	rcodes := make([]int, 0)
+	for _, name := range strings.Split(s, ",") {
+		if name == "" {
+			continue
+		}
+		rcode, ok := dns.StringToRcode[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown rcode %s", name)
+		}
+		rcodes = append(rcodes, rcode)
+	}
+	return rcodes, nil
+}
+
+// NewRetryPolicy constructs a retry policy from the retries and backoff options, negative retries meaning none
+func NewRetryPolicy(opts types.Options) RetryPolicy {
+	retries := opts.Retries
+	if retries < 0 {
+		retries = 0
+	}
+	p := RetryPolicy{
+		MaxAttempts:     retries + 1,
+		BaseDelay:       opts.RetryBackoff,
+		MaxDelay:        opts.RetryMaxBackoff,
+		RetryableRcodes: opts.RetryRcodes,
+	}
+	if p.BaseDelay == 0 {
+		p.BaseDelay = types.DEFAULTRETRYBACKOFF
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = types.DEFAULTRETRYMAXBACKOFF
+	}
+	if p.RetryableRcodes == nil {
+		p.RetryableRcodes = []int{dns.RcodeServerFailure}
+	}
+	return p
+}
+
+// Backoff returns the delay before the next attempt, doubling for each attempt up to the maximum delay.
+// The delay is jittered between half and all of its value so that retries from concurrent queries spread out.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 0; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// Retryable checks if a failed query should be retried.
+// Transport failures are always retried while rcode failures are only retried for the retryable rcodes.
+func (p RetryPolicy) Retryable(err error) bool {
+	var uerr *UpstreamError
+	if !errors.As(err, &uerr) {
+		return p.retryableFailure(err)
+	}
+
+	for _, f := range uerr.Failures {
+		if p.retryableFailure(f.Err) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableFailure checks if the failure of a single server should be retried
+func (p RetryPolicy) retryableFailure(err error) bool {
+	var rerr *rcodeFailure
+	if !errors.As(err, &rerr) {
+		return true
+	}
+	for _, rcode := range p.RetryableRcodes {
+		if rerr.rsp.Rcode == rcode {
+			return true
+		}
+	}
+	return false
+}
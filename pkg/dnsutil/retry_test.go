@@ -0,0 +1,143 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{10, time.Second},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			got := p.Backoff(tt.attempt)
+			if got < tt.want/2 || got >= tt.want {
+				t.Fatalf("Backoff(%d) = %s, want within [%s, %s)", tt.attempt, got, tt.want/2, tt.want)
+			}
+		}
+	}
+
+	if got := (RetryPolicy{}).Backoff(3); got != 0 {
+		t.Errorf("Backoff() without delays = %s, want 0", got)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	servfail := &rcodeFailure{rsp: &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeServerFailure}}}
+	refused := &rcodeFailure{rsp: &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused}}}
+	timeout := errors.New("i/o timeout")
+	upstreams := func(errs ...error) error {
+		uerr := &UpstreamError{Name: "example.com.", Qtype: dns.TypeA}
+		for _, err := range errs {
+			uerr.Failures = append(uerr.Failures, UpstreamFailure{Address: "10.0.0.1:53", Err: err})
+		}
+		return uerr
+	}
+
+	p := NewRetryPolicy(types.Options{})
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"transport failure", timeout, true},
+		{"server failure", servfail, true},
+		{"refusal", refused, false},
+		{"upstream transport failure", upstreams(timeout), true},
+		{"upstream server failure", upstreams(servfail), true},
+		{"upstream refusal", upstreams(refused), false},
+		{"refusal and transport failure", upstreams(refused, timeout), true},
+		{"refusals", upstreams(refused, refused), false},
+	}
+	for _, tt := range tests {
+		if got := p.Retryable(tt.err); got != tt.want {
+			t.Errorf("Retryable(%s) = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+
+	p.RetryableRcodes = []int{dns.RcodeRefused}
+	if p.Retryable(servfail) || !p.Retryable(upstreams(refused)) {
+		t.Errorf("Retryable() doesn't honor the retryable rcodes %v", p.RetryableRcodes)
+	}
+}
+
+func TestParseRcodes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"SERVFAIL", []int{dns.RcodeServerFailure}, false},
+		{"servfail,REFUSED", []int{dns.RcodeServerFailure, dns.RcodeRefused}, false},
+		{"", []int{}, false},
+		{"SERVFAIL,", []int{dns.RcodeServerFailure}, false},
+		{"NOPE", nil, true},
+		{"SERVFAIL,NOPE", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRcodes(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRcodes(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRcodes(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLookupFromRetriesServerFailures(t *testing.T) {
+	var n int32
+	answer := dnstest.Zone(t, "example.com. 300 IN SOA ns.example.com. hostmaster.example.com. 1 7200 3600 1209600 300")
+	address := dnstest.Start(t, "127.0.0.1:0", func(w dns.ResponseWriter, r *dns.Msg) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			dnstest.Rcode(dns.RcodeServerFailure)(w, r)
+			return
+		}
+		answer(w, r)
+	})
+
+	tests := []struct {
+		name      string
+		retries   int
+		wantRcode int
+	}{
+		{"retried", 1, dns.RcodeSuccess},
+		{"not retried", 0, dns.RcodeServerFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&n, 0)
+			resolver := NewResolverWithOptions(types.Options{Timeout: time.Second, Retries: tt.retries, RetryBackoff: time.Millisecond})
+			a, err := resolver.LookupFrom(context.Background(), address, "example.com.", dns.TypeSOA)
+			if err != nil {
+				t.Fatalf("LookupFrom() error = %v", err)
+			}
+			if a.Rcode != tt.wantRcode {
+				t.Errorf("LookupFrom() rcode = %s, want %s", dns.RcodeToString[a.Rcode], dns.RcodeToString[tt.wantRcode])
+			}
+			if got := atomic.LoadInt32(&n); got != int32(tt.retries+1) {
+				t.Errorf("%d queries sent, want %d", got, tt.retries+1)
+			}
+		})
+	}
+}
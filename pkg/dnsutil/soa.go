@@ -0,0 +1,88 @@
+package dnsutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// nameserverAddress contains an address of a nameserver
+type nameserverAddress struct {
+	name    string
+	address string
+}
+
+// nameserverAddresses returns the addresses of each nameserver, IPv4 addresses first, sorted by nameserver and address
+func nameserverAddresses(nameservers []types.NameserverInfo) []nameserverAddress {
+	addresses := make([]nameserverAddress, 0)
+	for _, ns := range nameservers {
+		for _, addressInfo := range []map[string][]types.ASNInfo{ns.IPv4AddressInfo, ns.IPv6AddressInfo} {
+			sorted := make([]string, 0, len(addressInfo))
+			for address := range addressInfo {
+				sorted = append(sorted, address)
+			}
+			sort.Strings(sorted)
+			for _, address := range sorted {
+				addresses = append(addresses, nameserverAddress{name: ns.Name, address: address})
+			}
+		}
+	}
+	return addresses
+}
+
+// SOAReport returns the SOA record served by each address of the nameservers of a zone and flags serials that differ
+func SOAReport(ctx context.Context, resolver *Resolver, nsInfo types.NSInfo) (types.SOAReport, error) {
+	report := types.SOAReport{Zone: nsInfo.Zone, Servers: make([]types.SOAInfo, 0), Serials: make([]uint32, 0)}
+
+	addresses := nameserverAddresses(nsInfo.Nameservers)
+	infos := make([]types.SOAInfo, len(addresses))
+	errs := make([]error, len(addresses))
+	forEach(len(addresses), resolver.concurrency, func(i int) {
+		infos[i], errs[i] = soaInfo(ctx, resolver, nsInfo.Zone, addresses[i])
+	})
+
+	serials := make(map[uint32]bool)
+	for i := range addresses {
+		report.Servers = append(report.Servers, infos[i])
+		if errs[i] != nil {
+			continue
+		}
+		if !serials[infos[i].Serial] {
+			serials[infos[i].Serial] = true
+			report.Serials = append(report.Serials, infos[i].Serial)
+		}
+	}
+	sort.Slice(report.Serials, func(i, j int) bool { return report.Serials[i] < report.Serials[j] })
+	report.SerialMismatch = len(report.Serials) > 1
+
+	return report, firstFailure(errs)
+}
+
+// soaInfo returns the SOA record of the zone served by an address of a nameserver, which fails unless it answers with one
+func soaInfo(ctx context.Context, resolver *Resolver, zone string, ns nameserverAddress) (types.SOAInfo, error) {
+	info := types.SOAInfo{Nameserver: ns.name, Address: ns.address}
+
+	a, err := resolver.LookupFrom(ctx, ns.address, zone, dns.TypeSOA)
+	if err == nil {
+		info.Authoritative = a.Authoritative
+		err = a.Err()
+	}
+	if err != nil {
+		err = fmt.Errorf("%s (%s): %v", ns.name, ns.address, err)
+		info.Error = err.Error()
+		return info, err
+	}
+
+	soa := Records[*dns.SOA](a.Answer)[0]
+	info.MName = soa.Ns
+	info.RName = soa.Mbox
+	info.Serial = soa.Serial
+	info.Refresh = soa.Refresh
+	info.Retry = soa.Retry
+	info.Expire = soa.Expire
+	info.Minimum = soa.Minttl
+	return info, nil
+}
@@ -0,0 +1,91 @@
+package dnsutil
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// startNameservers starts a server for each handler on 127.0.0.1, 127.0.0.2 and so on, all on the same port,
+// and returns a resolver querying nameservers on that port
+func startNameservers(t *testing.T, handlers ...dns.HandlerFunc) *Resolver {
+	_, port, err := net.SplitHostPort(dnstest.Start(t, "127.0.0.1:0", handlers[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, handler := range handlers[1:] {
+		dnstest.Start(t, "127.0.0."+strconv.Itoa(i+2)+":"+port, handler)
+	}
+
+	resolver := testResolver("127.0.0.1:" + port)
+	resolver.nameserverPort, err = strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver
+}
+
+func TestSOAReport(t *testing.T) {
+	resolver := startNameservers(t,
+		dnstest.Zone(t, "example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 1 7200 3600 1209600 300"),
+		dnstest.Zone(t, "example.test. 3600 IN SOA ns1.example.test. hostmaster.example.test. 2 7200 3600 1209600 300"),
+		dnstest.Rcode(dns.RcodeRefused),
+		delegating(t, "example.test. 3600 IN NS ns1.example.test."),
+	)
+
+	nameserver := func(name string, address string) types.NameserverInfo {
+		return types.NameserverInfo{Name: name, IPv4AddressInfo: map[string][]types.ASNInfo{address: {}}}
+	}
+	nsInfo := types.NSInfo{Zone: "example.test.", Nameservers: []types.NameserverInfo{
+		nameserver("ns1.example.test.", "127.0.0.1"),
+		nameserver("ns2.example.test.", "127.0.0.2"),
+		nameserver("ns3.example.test.", "127.0.0.3"),
+		nameserver("ns4.example.test.", "127.0.0.4"),
+	}}
+	report, err := SOAReport(context.Background(), resolver, nsInfo)
+	if err == nil {
+		t.Errorf("SOAReport() error = nil, want the failures of ns3 and ns4")
+	}
+
+	tests := []struct {
+		address       string
+		authoritative bool
+		serial        uint32
+		wantErr       bool
+	}{
+		{"127.0.0.1", true, 1, false},
+		{"127.0.0.2", true, 2, false},
+		{"127.0.0.3", false, 0, true},
+		{"127.0.0.4", false, 0, true},
+	}
+	if len(report.Servers) != len(tests) {
+		t.Fatalf("SOAReport() has %d servers, want %d", len(report.Servers), len(tests))
+	}
+	for i, tt := range tests {
+		got := report.Servers[i]
+		if got.Address != tt.address || got.Authoritative != tt.authoritative || got.Serial != tt.serial || (got.Error != "") != tt.wantErr {
+			t.Errorf("server %d = %+v, want address %s, authoritative %t, serial %d and error %t", i, got, tt.address, tt.authoritative, tt.serial, tt.wantErr)
+		}
+	}
+	if len(report.Serials) != 2 || report.Serials[0] != 1 || report.Serials[1] != 2 || !report.SerialMismatch {
+		t.Errorf("serials = %v, mismatch %t, want [1 2] and a mismatch", report.Serials, report.SerialMismatch)
+	}
+}
+
+func TestNameserverPort(t *testing.T) {
+	if got := NewResolverWithOptions(types.Options{Resolvers: []string{"127.0.0.1"}, Port: 5353}).nameserverPort; got != types.DEFAULTPORT {
+		t.Errorf("nameserver port = %d, want %d", got, types.DEFAULTPORT)
+	}
+	resolver, err := NewIterativeResolver(types.Options{Port: 5353})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolver.nameserverPort != 5353 {
+		t.Errorf("iterative nameserver port = %d, want 5353", resolver.nameserverPort)
+	}
+}
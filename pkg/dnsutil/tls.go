@@ -0,0 +1,49 @@
+package dnsutil
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// TLSSCHEME contains the scheme prefix of DNS-over-TLS resolver addresses
+const TLSSCHEME = "tls://"
+
+// isTLSAddress checks if the address is a DNS-over-TLS resolver
+func isTLSAddress(address string) bool {
+	return strings.HasPrefix(address, TLSSCHEME)
+}
+
+// newTLSConfig constructs the TLS configuration used for DNS-over-TLS resolvers.
+// When pins are given the certificate is trusted if the SPKI SHA-256 digest of the leaf certificate matches a pin,
+// which allows resolvers with self-signed certificates to be used. Only the leaf is checked since the handshake
+// proves the server holds its private key, while any other certificate of the chain could be copied from elsewhere.
+func newTLSConfig(serverName string, pins []string) *tls.Config {
+	config := &tls.Config{ServerName: serverName}
+	if len(pins) == 0 {
+		return config
+	}
+
+	config.InsecureSkipVerify = true
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("no certificate presented")
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		pin := base64.StdEncoding.EncodeToString(digest[:])
+		for _, p := range pins {
+			if p == pin {
+				return nil
+			}
+		}
+		return fmt.Errorf("the certificate doesn't match the pinned public keys")
+	}
+	return config
+}
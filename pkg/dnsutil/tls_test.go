@@ -0,0 +1,112 @@
+package dnsutil
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// selfSignedCert returns a self-signed certificate for 127.0.0.1 and the pin of its public key
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dns.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"dns.test"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, base64.StdEncoding.EncodeToString(digest[:])
+}
+
+// startTLS starts a DNS-over-TLS server presenting the certificate and returns its address
+func startTLS(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := dnstest.Zone(t, "example.com. 300 IN A 192.0.2.1")
+	s := &dns.Server{Listener: l, Net: "tcp-tls", Handler: handler}
+	started := make(chan struct{})
+	s.NotifyStartedFunc = func() { close(started) }
+	go s.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { s.Shutdown() })
+	return l.Addr().String()
+}
+
+func TestTLSPins(t *testing.T) {
+	pinned, pin := selfSignedCert(t)
+	attacker, attackerPin := selfSignedCert(t)
+
+	// The attacker holds the key of its own leaf and appends the public pinned certificate to the chain
+	appended := tls.Certificate{Certificate: [][]byte{attacker.Certificate[0], pinned.Certificate[0]}, PrivateKey: attacker.PrivateKey}
+
+	tests := []struct {
+		name    string
+		cert    tls.Certificate
+		pins    []string
+		wantErr bool
+	}{
+		{"pinned leaf", pinned, []string{pin}, false},
+		{"one of several pins", pinned, []string{attackerPin, pin}, false},
+		{"unpinned leaf", attacker, []string{pin}, true},
+		{"pinned certificate appended to the chain", appended, []string{pin}, true},
+		{"self-signed without pins", pinned, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address := startTLS(t, tt.cert)
+			r := NewResolverWithOptions(types.Options{
+				Resolvers:     []string{TLSSCHEME + address},
+				Timeout:       2 * time.Second,
+				TLSServerName: "dns.test",
+				TLSPins:       tt.pins,
+			})
+
+			res, err := r.LookupA(context.Background(), "example.com")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("LookupA() error = nil, want a certificate error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LookupA() error = %v", err)
+			}
+			if len(res) != 1 || res[0].A.String() != "192.0.2.1" {
+				t.Errorf("LookupA() = %v, want 192.0.2.1", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package dnsutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+)
+
+// trace collects the queries sent to nameservers while resolving names iteratively
+type trace struct {
+	mu    sync.Mutex
+	steps []types.TraceStep
+}
+
+// traceKey is the context key of the trace of a context
+type traceKey struct{}
+
+// WithTrace returns a context recording every query sent to a nameserver by an iterative resolver using it,
+// like dig +trace
+func WithTrace(ctx context.Context) context.Context {
+	return context.WithValue(ctx, traceKey{}, &trace{})
+}
+
+// TraceFromContext returns the queries recorded for a context returned by WithTrace, in the order they were sent
+func TraceFromContext(ctx context.Context) []types.TraceStep {
+	t, ok := ctx.Value(traceKey{}).(*trace)
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]types.TraceStep{}, t.steps...)
+}
+
+// traceStep records a query in the trace of the context, if any
+func traceStep(ctx context.Context, step types.TraceStep) {
+	if t, ok := ctx.Value(traceKey{}).(*trace); ok {
+		t.mu.Lock()
+		t.steps = append(t.steps, step)
+		t.mu.Unlock()
+	}
+}
@@ -0,0 +1,228 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// MAXCONSECUTIVEFAILURES contains the number of consecutive failures after which an upstream is considered unhealthy
+const MAXCONSECUTIVEFAILURES = 3
+
+// upstream represents an upstream resolver and tracks its health
+type upstream struct {
+	address string
+
+	mu                  sync.Mutex
+	successes           int
+	failures            int
+	consecutiveFailures int
+	lastError           error
+}
+
+// record updates the health of the upstream with the outcome of a query
+func (u *upstream) record(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err != nil {
+		u.failures++
+		u.consecutiveFailures++
+		u.lastError = err
+		return
+	}
+	u.successes++
+	u.consecutiveFailures = 0
+}
+
+// healthy checks if the upstream has not failed too many times in a row
+func (u *upstream) healthy() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.consecutiveFailures < MAXCONSECUTIVEFAILURES
+}
+
+// health returns a snapshot of the health of the upstream
+func (u *upstream) health() types.UpstreamHealth {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	h := types.UpstreamHealth{
+		Address:   u.address,
+		Successes: u.successes,
+		Failures:  u.failures,
+		Healthy:   u.consecutiveFailures < MAXCONSECUTIVEFAILURES,
+	}
+	if u.lastError != nil {
+		h.LastError = u.lastError.Error()
+	}
+	return h
+}
+
+// rcodeFailure is the error for an upstream that answered with a server failure or refusal
+type rcodeFailure struct {
+	rsp *dns.Msg
+}
+
+// Error returns the rcode of the response
+func (e *rcodeFailure) Error() string {
+	return fmt.Sprintf("lookup code %s", dns.RcodeToString[e.rsp.Rcode])
+}
+
+// UpstreamFailure contains the error returned by a single upstream resolver
+type UpstreamFailure struct {
+	Address string
+	Err     error
+}
+
+// UpstreamError is returned when every upstream resolver failed to answer a query
+type UpstreamError struct {
+	Name     string
+	Qtype    uint16
+	Failures []UpstreamFailure
+}
+
+// Error lists which upstream resolvers failed and why
+func (e *UpstreamError) Error() string {
+	failures := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		failures = append(failures, fmt.Sprintf("%s: %v", f.Address, f.Err))
+	}
+	return fmt.Sprintf("lookup %s %s failed on all upstreams (%s)", e.Name, dns.TypeToString[e.Qtype], strings.Join(failures, "; "))
+}
+
+// response returns the last response when every upstream answered with a server failure or refusal
+func (e *UpstreamError) response() *dns.Msg {
+	var rsp *dns.Msg
+	for _, f := range e.Failures {
+		rerr, ok := f.Err.(*rcodeFailure)
+		if !ok {
+			return nil
+		}
+		rsp = rerr.rsp
+	}
+	return rsp
+}
+
+// ParseResolvers parses a comma separated list of upstream resolver addresses, ignoring the spaces around them
+func ParseResolvers(s string) ([]string, error) {
+	resolvers := make([]string, 0)
+	for _, address := range strings.Split(s, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			return nil, fmt.Errorf("empty resolver address in %q", s)
+		}
+		resolvers = append(resolvers, address)
+	}
+	return resolvers, nil
+}
+
+// Health returns the health of each upstream resolver
+func (r *Resolver) Health() []types.UpstreamHealth {
+	health := make([]types.UpstreamHealth, 0, len(r.upstreams))
+	for _, u := range r.upstreams {
+		health = append(health, u.health())
+	}
+	return health
+}
+
+// orderedUpstreams returns the upstreams in the order they should be tried according to the strategy.
+// Unhealthy upstreams are moved to the end so they are only tried when the healthy ones fail.
+func (r *Resolver) orderedUpstreams() []*upstream {
+	start := 0
+	if r.strategy == types.STRATEGYROUNDROBIN {
+		start = int(atomic.AddUint32(&r.next, 1)-1) % len(r.upstreams)
+	}
+
+	healthy := make([]*upstream, 0, len(r.upstreams))
+	unhealthy := make([]*upstream, 0)
+	for i := range r.upstreams {
+		u := r.upstreams[(start+i)%len(r.upstreams)]
+		if u.healthy() {
+			healthy = append(healthy, u)
+		} else {
+			unhealthy = append(unhealthy, u)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// exchangeUpstreams sends the message to the upstreams according to the strategy
+func (r *Resolver) exchangeUpstreams(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	upstreams := r.orderedUpstreams()
+	if r.strategy == types.STRATEGYRACE {
+		return r.race(ctx, msg, upstreams)
+	}
+
+	uerr := &UpstreamError{Name: msg.Question[0].Name, Qtype: msg.Question[0].Qtype}
+	for _, u := range upstreams {
+		rsp, err := r.exchangeUpstream(ctx, msg, u)
+		if err == nil {
+			return rsp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		uerr.Failures = append(uerr.Failures, UpstreamFailure{Address: u.address, Err: err})
+	}
+	return nil, uerr
+}
+
+// race sends the message to all upstreams at once and returns the first successful response.
+// The queries still in flight are cancelled once a response is returned.
+func (r *Resolver) race(ctx context.Context, msg *dns.Msg, upstreams []*upstream) (*dns.Msg, error) {
+	type result struct {
+		rsp *dns.Msg
+		err error
+		u   *upstream
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(upstreams))
+	for _, u := range upstreams {
+		go func(u *upstream) {
+			rsp, err := r.exchangeUpstream(ctx, msg.Copy(), u)
+			results <- result{rsp: rsp, err: err, u: u}
+		}(u)
+	}
+
+	uerr := &UpstreamError{Name: msg.Question[0].Name, Qtype: msg.Question[0].Qtype}
+	for range upstreams {
+		res := <-results
+		if res.err == nil {
+			return res.rsp, nil
+		}
+		uerr.Failures = append(uerr.Failures, UpstreamFailure{Address: res.u.address, Err: res.err})
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, uerr
+}
+
+// exchangeUpstream sends the message to a single upstream and records the outcome.
+// Server failures and refusals are treated as failures so that another upstream is tried.
+// Queries cancelled before the upstream answered don't count against its health.
+func (r *Resolver) exchangeUpstream(ctx context.Context, msg *dns.Msg, u *upstream) (*dns.Msg, error) {
+	rsp, err := r.exchangeWith(ctx, msg, u.address)
+	if errors.Is(err, context.Canceled) {
+		return nil, err
+	}
+	if err == nil && (rsp.Rcode == dns.RcodeServerFailure || rsp.Rcode == dns.RcodeRefused) {
+		err = &rcodeFailure{rsp: rsp}
+	}
+	u.record(err)
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
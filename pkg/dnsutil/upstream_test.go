@@ -0,0 +1,198 @@
+package dnsutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marc-barry/domaininfo/internal/dnstest"
+	"github.com/marc-barry/domaininfo/pkg/types"
+	"github.com/miekg/dns"
+)
+
+// answering returns a handler answering A queries with the address after the delay and counting the queries in n
+func answering(a net.IP, delay time.Duration, n *int32) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		atomic.AddInt32(n, 1)
+		time.Sleep(delay)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: a})
+		w.WriteMsg(m)
+	}
+}
+
+// silent returns the address of a UDP socket that never answers
+func silent(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("listening: %v", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc.LocalAddr().String()
+}
+
+// lookupAddress looks up the A record of www.example.com and returns its address
+func lookupAddress(t *testing.T, resolver *Resolver) string {
+	t.Helper()
+	res, err := resolver.LookupA(context.Background(), "www.example.com.")
+	if err != nil {
+		t.Fatalf("LookupA() error = %v", err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("LookupA() = %v, want one record", res)
+	}
+	return res[0].A.String()
+}
+
+// failures returns the number of failures recorded for each upstream
+func failures(resolver *Resolver) []int {
+	out := make([]int, 0)
+	for _, h := range resolver.Health() {
+		out = append(out, h.Failures)
+	}
+	return out
+}
+
+func TestFailover(t *testing.T) {
+	var n int32
+	servfail := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	good := dnstest.Start(t, "127.0.0.1:0", answering(net.IPv4(192, 0, 2, 1), 0, &n))
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{servfail, good}, Timeout: time.Second})
+
+	for i := 0; i < MAXCONSECUTIVEFAILURES+1; i++ {
+		if got := lookupAddress(t, resolver); got != "192.0.2.1" {
+			t.Errorf("LookupA() = %s, want 192.0.2.1", got)
+		}
+	}
+	// The failing upstream is no longer tried first once it's unhealthy
+	if got, want := failures(resolver), []int{MAXCONSECUTIVEFAILURES, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failures = %v, want %v", got, want)
+	}
+	if health := resolver.Health(); health[0].Healthy || !health[1].Healthy || health[1].Successes != MAXCONSECUTIVEFAILURES+1 {
+		t.Errorf("health = %+v, want only the second upstream healthy", health)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	var n1, n2 int32
+	first := dnstest.Start(t, "127.0.0.1:0", answering(net.IPv4(192, 0, 2, 1), 0, &n1))
+	second := dnstest.Start(t, "127.0.0.1:0", answering(net.IPv4(192, 0, 2, 2), 0, &n2))
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{first, second}, Strategy: types.STRATEGYROUNDROBIN, Timeout: time.Second})
+
+	got := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		got = append(got, lookupAddress(t, resolver))
+	}
+	if want := []string{"192.0.2.1", "192.0.2.2", "192.0.2.1", "192.0.2.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("answers = %v, want %v", got, want)
+	}
+	if n1, n2 := atomic.LoadInt32(&n1), atomic.LoadInt32(&n2); n1 != 2 || n2 != 2 {
+		t.Errorf("queries = %d and %d, want 2 each", n1, n2)
+	}
+}
+
+func TestRace(t *testing.T) {
+	var n1, n2 int32
+	slow := dnstest.Start(t, "127.0.0.1:0", answering(net.IPv4(192, 0, 2, 1), 200*time.Millisecond, &n1))
+	fast := dnstest.Start(t, "127.0.0.1:0", answering(net.IPv4(192, 0, 2, 2), 0, &n2))
+	servfail := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{slow, servfail, fast}, Strategy: types.STRATEGYRACE, Timeout: time.Second})
+
+	start := time.Now()
+	if got := lookupAddress(t, resolver); got != "192.0.2.2" {
+		t.Errorf("LookupA() = %s, want the fastest answer 192.0.2.2", got)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf("LookupA() took %s, want less than the slow upstream", elapsed)
+	}
+	if n := atomic.LoadInt32(&n2); n != 1 {
+		t.Errorf("fastest upstream got %d queries, want 1", n)
+	}
+
+	// The cancelled query to the slow upstream isn't a failure, while the server failure may or may not
+	// have been received before the fastest answer
+	if got := failures(resolver); got[0] != 0 || got[2] != 0 {
+		t.Errorf("failures = %v, want none for the answering upstreams", got)
+	}
+}
+
+func TestRaceRecordsFailures(t *testing.T) {
+	servfail := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	refused := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeRefused))
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{servfail, refused}, Strategy: types.STRATEGYRACE, Timeout: time.Second})
+
+	a, err := resolver.Lookup(context.Background(), "www.example.com.", dns.TypeA)
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if a.Rcode != dns.RcodeServerFailure && a.Rcode != dns.RcodeRefused {
+		t.Errorf("Lookup() rcode = %s, want the rcode of a failed upstream", dns.RcodeToString[a.Rcode])
+	}
+	if got, want := failures(resolver), []int{1, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failures = %v, want %v", got, want)
+	}
+}
+
+func TestUpstreamError(t *testing.T) {
+	unanswered := silent(t)
+	servfail := dnstest.Start(t, "127.0.0.1:0", dnstest.Rcode(dns.RcodeServerFailure))
+	resolver := NewResolverWithOptions(types.Options{Resolvers: []string{unanswered, servfail}, Timeout: 100 * time.Millisecond})
+
+	_, err := resolver.LookupA(context.Background(), "www.example.com.")
+	var uerr *UpstreamError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("LookupA() error = %v, want an *UpstreamError", err)
+	}
+	if len(uerr.Failures) != 2 || uerr.Failures[0].Address != unanswered || uerr.Failures[1].Address != servfail {
+		t.Errorf("failures = %+v, want both upstreams in order", uerr.Failures)
+	}
+	msg := err.Error()
+	prefix := "lookup www.example.com. A failed on all upstreams (" + unanswered + ": "
+	if !strings.HasPrefix(msg, prefix) || !strings.HasSuffix(msg, "; "+servfail+": lookup code SERVFAIL)") {
+		t.Errorf("error = %q, want both upstreams and their errors", msg)
+	}
+}
+
+func TestUpstreamErrorMessage(t *testing.T) {
+	err := &UpstreamError{Name: "example.com.", Qtype: dns.TypeMX, Failures: []UpstreamFailure{
+		{Address: "10.0.0.1:53", Err: errors.New("i/o timeout")},
+		{Address: "10.0.0.2:53", Err: &rcodeFailure{rsp: &dns.Msg{MsgHdr: dns.MsgHdr{Rcode: dns.RcodeRefused}}}},
+	}}
+	want := "lookup example.com. MX failed on all upstreams (10.0.0.1:53: i/o timeout; 10.0.0.2:53: lookup code REFUSED)"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if err.response() != nil {
+		t.Errorf("response() returned a response although an upstream didn't answer")
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{"1.1.1.1", []string{"1.1.1.1"}, false},
+		{"1.1.1.1, 8.8.8.8:53 ,https://dns.example/dns-query", []string{"1.1.1.1", "8.8.8.8:53", "https://dns.example/dns-query"}, false},
+		{"1.1.1.1,,8.8.8.8", nil, true},
+		{"1.1.1.1,", nil, true},
+		{" ", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseResolvers(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseResolvers(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseResolvers(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
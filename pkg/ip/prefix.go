@@ -0,0 +1,110 @@
+package ip
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+)
+
+// ParsePrefix parses a prefix in CIDR notation, masking the host bits and unmapping IPv4-mapped IPv6 prefixes
+func ParsePrefix(s string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if p.Addr().Is4In6() {
+		if p.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("IPv4-mapped prefix %s is shorter than /96", s)
+		}
+		p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+	}
+	return p.Masked(), nil
+}
+
+// Blocks splits the prefix into at most max blocks of equal size, largest power of two first.
+// When the prefix has no more than max addresses each block is a single address.
+func Blocks(p netip.Prefix, max int) []netip.Prefix {
+	p = p.Masked()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	sampleBits := 0
+	for sampleBits < hostBits && sampleBits < 62 && (2<<sampleBits) <= max {
+		sampleBits++
+	}
+	bits := p.Bits() + sampleBits
+
+	n := 1 << (bits - p.Bits())
+	blocks := make([]netip.Prefix, 0, n)
+	addr := p.Addr()
+	for i := 0; i < n; i++ {
+		blocks = append(blocks, netip.PrefixFrom(addr, bits))
+		addr = nextBlock(addr, bits)
+	}
+	return blocks
+}
+
+// nextBlock returns the first address of the block of the given length following the block of the address
+func nextBlock(addr netip.Addr, bits int) netip.Addr {
+	b := addr.As16()
+	offset := 0
+	if addr.Is4() {
+		offset = 96
+	}
+	pos := offset + bits - 1
+	for i := pos / 8; i >= 0; i-- {
+		inc := byte(1)
+		if i == pos/8 {
+			inc = 1 << (7 - pos%8)
+		}
+		b[i] += inc
+		if b[i] >= inc {
+			break
+		}
+	}
+
+	next := netip.AddrFrom16(b)
+	if addr.Is4() {
+		next = next.Unmap()
+	}
+	return next
+}
+
+// Summarize returns the minimal list of prefixes covering the same addresses as the prefixes,
+// merging sibling prefixes into their parent and dropping prefixes covered by another.
+func Summarize(prefixes []netip.Prefix) []netip.Prefix {
+	sorted := make([]netip.Prefix, 0, len(prefixes))
+	for _, p := range prefixes {
+		sorted = append(sorted, p.Masked())
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Addr() != sorted[j].Addr() {
+			return sorted[i].Addr().Less(sorted[j].Addr())
+		}
+		return sorted[i].Bits() < sorted[j].Bits()
+	})
+
+	summary := make([]netip.Prefix, 0, len(sorted))
+	for _, p := range sorted {
+		if len(summary) != 0 && covers(summary[len(summary)-1], p) {
+			continue
+		}
+		summary = append(summary, p)
+		for len(summary) >= 2 && siblings(summary[len(summary)-2], summary[len(summary)-1]) {
+			parent := netip.PrefixFrom(p.Addr(), summary[len(summary)-1].Bits()-1).Masked()
+			summary = append(summary[:len(summary)-2], parent)
+		}
+	}
+	return summary
+}
+
+// covers checks if the prefix a contains all the addresses of the prefix b
+func covers(a netip.Prefix, b netip.Prefix) bool {
+	return a.Bits() <= b.Bits() && a.Contains(b.Addr())
+}
+
+// siblings checks if the prefixes are the two halves of the same parent prefix
+func siblings(a netip.Prefix, b netip.Prefix) bool {
+	if a == b || a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+		return false
+	}
+	return netip.PrefixFrom(a.Addr(), a.Bits()-1).Masked() == netip.PrefixFrom(b.Addr(), b.Bits()-1).Masked()
+}
@@ -0,0 +1,114 @@
+package ip
+
+import (
+	"math"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// prefixes parses a list of prefixes
+func prefixes(t *testing.T, strs ...string) []netip.Prefix {
+	t.Helper()
+	ps := make([]netip.Prefix, 0, len(strs))
+	for _, s := range strs {
+		ps = append(ps, netip.MustParsePrefix(s))
+	}
+	return ps
+}
+
+func TestParsePrefix(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"151.101.0.0/22", "151.101.0.0/22", false},
+		{"151.101.1.67/22", "151.101.0.0/22", false},
+		{"2a04:4e42::/32", "2a04:4e42::/32", false},
+		{"::ffff:10.0.0.0/120", "10.0.0.0/24", false},
+		{"::ffff:10.0.0.0/80", "", true},
+		{"10.0.0.0/33", "", true},
+		{"example.com", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePrefix(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePrefix(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParsePrefix(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		max       int
+		wantCount int
+		wantFirst string
+		wantLast  string
+	}{
+		{"every address", "10.0.0.0/30", 256, 4, "10.0.0.0/32", "10.0.0.3/32"},
+		{"sampled", "151.101.0.0/22", 256, 256, "151.101.0.0/30", "151.101.3.252/30"},
+		{"rounded down to a power of two", "151.101.0.0/22", 100, 64, "151.101.0.0/28", "151.101.3.240/28"},
+		{"single block", "151.101.0.0/22", 1, 1, "151.101.0.0/22", "151.101.0.0/22"},
+		{"non-positive max", "151.101.0.0/22", 0, 1, "151.101.0.0/22", "151.101.0.0/22"},
+		{"host bits masked", "10.0.0.3/30", 256, 4, "10.0.0.0/32", "10.0.0.3/32"},
+		{"end of the address space", "255.255.255.0/24", 256, 256, "255.255.255.0/32", "255.255.255.255/32"},
+		{"ipv6", "2a04:4e40::/32", 64, 64, "2a04:4e40::/38", "2a04:4e40:fc00::/38"},
+		{"huge max", "10.0.0.0/30", math.MaxInt, 4, "10.0.0.0/32", "10.0.0.3/32"},
+		{"huge max ipv6 host", "2a04:4e40::1/128", math.MaxInt, 1, "2a04:4e40::1/128", "2a04:4e40::1/128"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks := Blocks(netip.MustParsePrefix(tt.prefix), tt.max)
+			if len(blocks) != tt.wantCount {
+				t.Fatalf("Blocks() returned %d blocks, want %d", len(blocks), tt.wantCount)
+			}
+			if got := blocks[0].String(); got != tt.wantFirst {
+				t.Errorf("first block = %s, want %s", got, tt.wantFirst)
+			}
+			if got := blocks[len(blocks)-1].String(); got != tt.wantLast {
+				t.Errorf("last block = %s, want %s", got, tt.wantLast)
+			}
+			if got := Summarize(blocks); len(got) != 1 || got[0] != netip.MustParsePrefix(tt.prefix).Masked() {
+				t.Errorf("Summarize(Blocks()) = %v, want %s", got, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"empty", nil, []string{}},
+		{"siblings", []string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{"unsorted siblings", []string{"10.0.0.128/25", "10.0.0.0/25"}, []string{"10.0.0.0/24"}},
+		{"cascading merges", []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/25"}, []string{"10.0.0.0/24"}},
+		{"adjacent but not siblings", []string{"10.0.0.128/25", "10.0.1.0/25"}, []string{"10.0.0.128/25", "10.0.1.0/25"}},
+		{"covered", []string{"10.0.0.0/24", "10.0.0.64/26"}, []string{"10.0.0.0/24"}},
+		{"duplicates", []string{"10.0.0.0/24", "10.0.0.0/24"}, []string{"10.0.0.0/24"}},
+		{"gap", []string{"10.0.0.0/32", "10.0.0.1/32", "10.0.0.3/32"}, []string{"10.0.0.0/31", "10.0.0.3/32"}},
+		{"host bits masked", []string{"10.0.0.1/25", "10.0.0.129/25"}, []string{"10.0.0.0/24"}},
+		{"ipv6", []string{"2a04:4e40::/33", "2a04:4e40:8000::/33"}, []string{"2a04:4e40::/32"}},
+		{"families kept apart", []string{"0.0.0.0/1", "128.0.0.0/1", "::/1"}, []string{"0.0.0.0/0", "::/1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make([]string, 0)
+			for _, p := range Summarize(prefixes(t, tt.in...)) {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Summarize(%v) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
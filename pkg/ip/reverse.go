@@ -0,0 +1,79 @@
+package ip
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// INADDRARPA contains the zone of the reverse names of IPv4 addresses
+const INADDRARPA = "in-addr.arpa"
+
+// IP6ARPA contains the zone of the reverse names of IPv6 addresses
+const IP6ARPA = "ip6.arpa"
+
+// AddrFromIP converts an IP address to a netip.Addr, unmapping IPv4 addresses stored in the 16 byte form
+func AddrFromIP(ip net.IP) (netip.Addr, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, fmt.Errorf("invalid IP address of length %d", len(ip))
+	}
+	return addr.Unmap(), nil
+}
+
+// ReverseLabels returns the labels of an address in reverse order, the decimal octets of an IPv4 address
+// or the hexadecimal nibbles of an IPv6 address. IPv4-mapped IPv6 addresses are reversed as the IPv4 address they map.
+func ReverseLabels(addr netip.Addr) ([]string, error) {
+	if !addr.IsValid() {
+		return nil, fmt.Errorf("invalid IP address")
+	}
+	addr = addr.Unmap()
+
+	if addr.Is4() {
+		b := addr.As4()
+		labels := make([]string, len(b))
+		for i := 0; i < len(b); i++ {
+			labels[len(b)-1-i] = strconv.Itoa(int(b[i]))
+		}
+		return labels, nil
+	}
+
+	b := addr.As16()
+	labels := make([]string, 2*len(b))
+	for i := 0; i < len(b); i++ {
+		labels[len(labels)-1-2*i] = strconv.FormatUint(uint64(b[i]>>4), 16)
+		labels[len(labels)-2-2*i] = strconv.FormatUint(uint64(b[i]&0xf), 16)
+	}
+	return labels, nil
+}
+
+// ReverseName returns the fully qualified name made of the reversed labels of an address followed by the zone,
+// such as in-addr.arpa, ip6.arpa or origin.asn.cymru.com. The in-addr.arpa and ip6.arpa zones only take addresses of their family.
+func ReverseName(addr netip.Addr, zone string) (string, error) {
+	labels, err := ReverseLabels(addr)
+	if err != nil {
+		return "", err
+	}
+	is4 := addr.Unmap().Is4()
+	switch strings.ToLower(strings.TrimSuffix(zone, ".")) {
+	case INADDRARPA:
+		if !is4 {
+			return "", fmt.Errorf("IPv6 address %s has no name in %s", addr, INADDRARPA)
+		}
+	case IP6ARPA:
+		if is4 {
+			return "", fmt.Errorf("IPv4 address %s has no name in %s", addr, IP6ARPA)
+		}
+	}
+	return strings.Join(labels, ".") + "." + strings.TrimSuffix(zone, ".") + ".", nil
+}
+
+// ARPAName returns the in-addr.arpa name of an IPv4 address or the ip6.arpa name of an IPv6 address, used for PTR lookups
+func ARPAName(addr netip.Addr) (string, error) {
+	if addr.Unmap().Is4() {
+		return ReverseName(addr, INADDRARPA)
+	}
+	return ReverseName(addr, IP6ARPA)
+}
@@ -0,0 +1,139 @@
+package ip
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestAddrFromIP(t *testing.T) {
+	tests := []struct {
+		in      net.IP
+		want    string
+		wantErr bool
+	}{
+		{net.IPv4(151, 101, 1, 67), "151.101.1.67", false},
+		{net.IPv4(151, 101, 1, 67).To4(), "151.101.1.67", false},
+		{net.ParseIP("2a04:4e42::323"), "2a04:4e42::323", false},
+		{net.IP{1, 2, 3}, "", true},
+		{nil, "", true},
+	}
+	for _, tt := range tests {
+		got, err := AddrFromIP(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("AddrFromIP(%v) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("AddrFromIP(%v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    netip.Addr
+		zone    string
+		want    string
+		wantErr bool
+	}{
+		{"ipv4", netip.MustParseAddr("151.101.1.67"), INADDRARPA, "67.1.101.151.in-addr.arpa.", false},
+		{"ipv4 cymru", netip.MustParseAddr("151.101.1.67"), "origin.asn.cymru.com", "67.1.101.151.origin.asn.cymru.com.", false},
+		{"ipv4 fully qualified zone", netip.MustParseAddr("10.0.0.1"), "in-addr.arpa.", "1.0.0.10.in-addr.arpa.", false},
+		{"ipv4-mapped", netip.MustParseAddr("::ffff:151.101.1.67"), INADDRARPA, "67.1.101.151.in-addr.arpa.", false},
+		{"ipv6", netip.MustParseAddr("2a04:4e42::323"), IP6ARPA,
+			"3.2.3.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.4.e.4.4.0.a.2.ip6.arpa.", false},
+		{"ipv6 cymru", netip.MustParseAddr("2a04:4e42::323"), "origin6.asn.cymru.com",
+			"3.2.3.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.4.e.4.4.0.a.2.origin6.asn.cymru.com.", false},
+		{"ipv6 unspecified", netip.IPv6Unspecified(), IP6ARPA, strings.Repeat("0.", 32) + "ip6.arpa.", false},
+		{"ipv6 zone index", netip.MustParseAddr("fe80::1%eth0"), IP6ARPA,
+			"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.e.f.ip6.arpa.", false},
+		{"ipv6 in in-addr.arpa", netip.MustParseAddr("2a04:4e42::323"), INADDRARPA, "", true},
+		{"ipv4 in ip6.arpa", netip.MustParseAddr("151.101.1.67"), "IP6.ARPA.", "", true},
+		{"ipv4-mapped in ip6.arpa", netip.MustParseAddr("::ffff:151.101.1.67"), IP6ARPA, "", true},
+		{"invalid", netip.Addr{}, INADDRARPA, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReverseName(tt.addr, tt.zone)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReverseName() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReverseName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestARPAName(t *testing.T) {
+	tests := []struct {
+		addr netip.Addr
+		want string
+	}{
+		{netip.MustParseAddr("10.0.0.1"), "1.0.0.10.in-addr.arpa."},
+		{netip.MustParseAddr("::ffff:10.0.0.1"), "1.0.0.10.in-addr.arpa."},
+		{netip.MustParseAddr("::1"), "1." + strings.Repeat("0.", 31) + "ip6.arpa."},
+	}
+	for _, tt := range tests {
+		got, err := ARPAName(tt.addr)
+		if err != nil {
+			t.Errorf("ARPAName(%s) error = %v", tt.addr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ARPAName(%s) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+	if _, err := ARPAName(netip.Addr{}); err == nil {
+		t.Errorf("ARPAName() of an invalid address didn't fail")
+	}
+}
+
+func FuzzARPAName(f *testing.F) {
+	for _, s := range []string{"151.101.1.67", "0.0.0.0", "255.255.255.255", "2a04:4e42::323", "::ffff:10.0.0.1", "::"} {
+		f.Add(netip.MustParseAddr(s).AsSlice())
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		addr, ok := netip.AddrFromSlice(b)
+		if !ok {
+			return
+		}
+		name, err := ARPAName(addr)
+		if err != nil {
+			t.Fatalf("ARPAName(%s) error = %v", addr, err)
+		}
+		addr = addr.Unmap()
+		zone, want := IP6ARPA, 32
+		if addr.Is4() {
+			zone, want = INADDRARPA, 4
+		}
+		if !strings.HasSuffix(name, "."+zone+".") {
+			t.Fatalf("ARPAName(%s) = %q isn't in %s", addr, name, zone)
+		}
+		labels := strings.Split(strings.TrimSuffix(name, "."+zone+"."), ".")
+		if len(labels) != want {
+			t.Fatalf("ARPAName(%s) = %q has %d labels, want %d", addr, name, len(labels), want)
+		}
+
+		// Reversing the labels back must give the address again
+		var back netip.Addr
+		if addr.Is4() {
+			back, err = netip.ParseAddr(labels[3] + "." + labels[2] + "." + labels[1] + "." + labels[0])
+		} else {
+			var sb strings.Builder
+			for i := len(labels) - 1; i >= 0; i-- {
+				sb.WriteString(labels[i])
+				if i%4 == 0 && i != 0 {
+					sb.WriteByte(':')
+				}
+			}
+			back, err = netip.ParseAddr(sb.String())
+		}
+		if err != nil || back != addr {
+			t.Fatalf("ARPAName(%s) = %q doesn't reverse back to the address: %v %v", addr, name, back, err)
+		}
+	})
+}
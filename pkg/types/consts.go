@@ -17,3 +17,18 @@ const IPv6LOOKUPTEMPLATE = "%s." + IPv6ORIGINLOOKUPDNSSERVER
 
 // ASNLOOKUPTEMPLATE is the template for looking up ASN descriptions
 const ASNLOOKUPTEMPLATE = "AS%s." + ASNLOOKUPDNSSERVER
+
+// DELEGATIONOK is the status of a nameserver answering authoritatively with the NS records of its zone
+const DELEGATIONOK = "ok"
+
+// DELEGATIONLAME is the status of a nameserver answering without authority for its zone
+const DELEGATIONLAME = "lame"
+
+// DELEGATIONREFUSED is the status of a nameserver refusing to answer for its zone
+const DELEGATIONREFUSED = "refused"
+
+// DELEGATIONTIMEOUT is the status of a nameserver not answering in time
+const DELEGATIONTIMEOUT = "timeout"
+
+// DELEGATIONERROR is the status of a nameserver failing to answer for another reason
+const DELEGATIONERROR = "error"
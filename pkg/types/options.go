@@ -0,0 +1,85 @@
+package types
+
+import "time"
+
+// DEFAULTRESOLVER contains the address of the upstream resolver used when none is given
+const DEFAULTRESOLVER = "1.1.1.1"
+
+// DEFAULTPORT contains the port used for upstream resolvers given without one
+const DEFAULTPORT = 53
+
+// DEFAULTTLSPORT contains the port used for DNS-over-TLS resolvers given without one
+const DEFAULTTLSPORT = 853
+
+// DEFAULTTIMEOUT contains the timeout used for DNS queries when none is given
+const DEFAULTTIMEOUT = 5 * time.Second
+
+// DEFAULTRETRIES contains the retries option meaning unset, which uses the attempts of the resolv.conf file or else no retries
+const DEFAULTRETRIES = -1
+
+// DEFAULTRETRYBACKOFF contains the delay before the first retry of a failed DNS query
+const DEFAULTRETRYBACKOFF = 100 * time.Millisecond
+
+// DEFAULTRETRYMAXBACKOFF contains the maximum delay between retries of a failed DNS query
+const DEFAULTRETRYMAXBACKOFF = 2 * time.Second
+
+// DEFAULTCACHEMAXAGE contains the age after which ASN lookups kept in the cache directory are looked up again
+const DEFAULTCACHEMAXAGE = 24 * time.Hour
+
+// DEFAULTCONCURRENCY contains the number of DNS queries a resolver has in flight at once
+const DEFAULTCONCURRENCY = 8
+
+// DEFAULTWORKERS contains the number of domains looked up at once in bulk mode
+const DEFAULTWORKERS = 4
+
+// DEFAULTMAXADDRESSES contains the number of addresses of a prefix looked up before sampling one address per block
+const DEFAULTMAXADDRESSES = 256
+
+// DEFAULTUDPSIZE contains the EDNS0 UDP buffer size advertised on queries
+const DEFAULTUDPSIZE = 1232
+
+// DEFAULTRESOLVCONF contains the path of the system resolver configuration
+const DEFAULTRESOLVCONF = "/etc/resolv.conf"
+
+// STRATEGYFAILOVER tries the upstream resolvers in order until one answers
+const STRATEGYFAILOVER = "failover"
+
+// STRATEGYROUNDROBIN rotates the upstream resolver tried first for each query
+const STRATEGYROUNDROBIN = "round-robin"
+
+// STRATEGYRACE sends each query to all upstream resolvers and uses the fastest answer
+const STRATEGYRACE = "race"
+
+// Options contains the settings for a domaininfo run
+type Options struct {
+	Resolvers       []string
+	Strategy        string
+	ResolvConf      string
+	Port            int
+	Timeout         time.Duration
+	Deadline        time.Duration
+	Retries         int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+	RetryRcodes     []int
+	IPv4Only        bool
+	IPv6Only        bool
+	UDPSize         uint16
+	DoHMethod       string
+	TLSServerName   string
+	TLSPins         []string
+	Concurrency     int
+	Workers         int
+	MaxAddresses    int
+	DNSCache        bool
+	CacheDir        string
+	CacheMaxAge     time.Duration
+	NoCache         bool
+	RefreshCache    bool
+	ASNDataset      string
+	CheckSOA        bool
+	CheckDelegation bool
+	Iterative       bool
+	RootHints       string
+	Debug           bool
+}
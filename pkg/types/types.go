@@ -24,12 +24,164 @@ type CAAInfo struct {
 	CAs    []string `json:"cas"`
 }
 
+// UpstreamHealth contains the health of an upstream resolver
+type UpstreamHealth struct {
+	Address   string `json:"address"`
+	Successes int    `json:"successes"`
+	Failures  int    `json:"failures"`
+	LastError string `json:"lastError,omitempty"`
+	Healthy   bool   `json:"healthy"`
+}
+
+// Metadata contains statistics about the DNS queries made
+type Metadata struct {
+	Queries     uint64 `json:"queries"`
+	Retries     uint64 `json:"retries"`
+	CacheHits   uint64 `json:"cacheHits"`
+	CacheMisses uint64 `json:"cacheMisses"`
+}
+
 // DomainInfo contains all domain information
 type DomainInfo struct {
 	Domain                string               `json:"domain"`
 	CanonicalNamesTargets []string             `json:"canonicalNamesTargets"`
 	IPv4AddressInfo       map[string][]ASNInfo `json:"ipv4AddressInfo"`
 	IPv6AddressInfo       map[string][]ASNInfo `json:"ipv6AddressInfo"`
+	PTRInfo               map[string]PTRInfo   `json:"ptrInfo"`
+	MXInfos               []MXInfo             `json:"mxInfos"`
+	NSInfo                NSInfo               `json:"nsInfo"`
+	SOAReport             *SOAReport           `json:"soaReport,omitempty"`
+	DelegationReport      *DelegationReport    `json:"delegationReport,omitempty"`
+	Trace                 []TraceStep          `json:"trace,omitempty"`
 	ASNDescriptions       []ASNDescription     `json:"asnDescriptions"`
 	CAAInfos              []CAAInfo            `json:"caaInfos"`
+	QueryOutcomes         map[string]string    `json:"queryOutcomes"`
+	Errors                map[string]string    `json:"errors,omitempty"`
+	Metadata              Metadata             `json:"metadata"`
+}
+
+// IPInfo contains all IP address information
+type IPInfo struct {
+	IP              string            `json:"ip"`
+	ASNInfo         []ASNInfo         `json:"asnInfo"`
+	PTRInfo         PTRInfo           `json:"ptrInfo"`
+	ASNDescriptions []ASNDescription  `json:"asnDescriptions"`
+	QueryOutcomes   map[string]string `json:"queryOutcomes"`
+	Errors          map[string]string `json:"errors,omitempty"`
+	Metadata        Metadata          `json:"metadata"`
+}
+
+// ASNReport contains all ASN information
+type ASNReport struct {
+	ASN             string            `json:"asn"`
+	ASNDescriptions []ASNDescription  `json:"asnDescriptions"`
+	Prefixes        []string          `json:"prefixes,omitempty"`
+	Errors          map[string]string `json:"errors,omitempty"`
+	Metadata        Metadata          `json:"metadata"`
+}
+
+// PrefixInfo contains all prefix information
+type PrefixInfo struct {
+	Prefix          string               `json:"prefix"`
+	Sampled         bool                 `json:"sampled"`
+	BlockLength     int                  `json:"blockLength"`
+	AddressInfo     map[string][]ASNInfo `json:"addressInfo"`
+	ASNPrefixes     map[string][]string  `json:"asnPrefixes"`
+	Unannounced     []string             `json:"unannounced"`
+	Failed          []string             `json:"failed"`
+	ASNDescriptions []ASNDescription     `json:"asnDescriptions"`
+	Errors          map[string]string    `json:"errors,omitempty"`
+	Metadata        Metadata             `json:"metadata"`
+}
+
+// PTRInfo contains the names an IP address points to and whether they point back to it (FCrDNS)
+type PTRInfo struct {
+	Outcome          string   `json:"outcome"`
+	Names            []string `json:"names"`
+	ForwardConfirmed bool     `json:"forwardConfirmed"`
+	Mismatches       []string `json:"mismatches"`
+}
+
+// MXInfo contains a mail exchanger of a domain with the ASN info of its IP addresses
+type MXInfo struct {
+	Exchange        string               `json:"exchange"`
+	Preference      uint16               `json:"preference"`
+	IPv4AddressInfo map[string][]ASNInfo `json:"ipv4AddressInfo"`
+	IPv6AddressInfo map[string][]ASNInfo `json:"ipv6AddressInfo"`
+}
+
+// NameserverInfo contains a nameserver of a zone with the ASN info of its IP addresses
+type NameserverInfo struct {
+	Name            string               `json:"name"`
+	IPv4AddressInfo map[string][]ASNInfo `json:"ipv4AddressInfo"`
+	IPv6AddressInfo map[string][]ASNInfo `json:"ipv6AddressInfo"`
+}
+
+// NSInfo contains the nameservers delegated for the zone of a domain and the distinct ASNs and countries hosting them
+type NSInfo struct {
+	Zone        string           `json:"zone"`
+	Nameservers []NameserverInfo `json:"nameservers"`
+	ASNs        []string         `json:"asns"`
+	Countries   []string         `json:"countries"`
+}
+
+// SOAInfo contains the SOA record served by an address of a nameserver
+type SOAInfo struct {
+	Nameserver    string `json:"nameserver"`
+	Address       string `json:"address"`
+	Authoritative bool   `json:"authoritative"`
+	MName         string `json:"mname"`
+	RName         string `json:"rname"`
+	Serial        uint32 `json:"serial"`
+	Refresh       uint32 `json:"refresh"`
+	Retry         uint32 `json:"retry"`
+	Expire        uint32 `json:"expire"`
+	Minimum       uint32 `json:"minimum"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SOAReport contains the SOA records served by each nameserver of a zone and whether their serials differ
+type SOAReport struct {
+	Zone           string    `json:"zone"`
+	Servers        []SOAInfo `json:"servers"`
+	Serials        []uint32  `json:"serials"`
+	SerialMismatch bool      `json:"serialMismatch"`
+}
+
+// DelegationServer contains the answer of an address of a nameserver to the NS query of its zone
+type DelegationServer struct {
+	Nameserver  string   `json:"nameserver"`
+	Address     string   `json:"address"`
+	Status      string   `json:"status"`
+	Nameservers []string `json:"nameservers"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// DelegationReport contains the delegation of a zone by its parent, the answers of its nameservers
+// and the lame nameservers and disagreements between the parent and the child zones
+type DelegationReport struct {
+	Zone              string             `json:"zone"`
+	ParentZone        string             `json:"parentZone"`
+	ParentServer      string             `json:"parentServer"`
+	ParentNameservers []string           `json:"parentNameservers"`
+	ChildNameservers  []string           `json:"childNameservers"`
+	ParentOnly        []string           `json:"parentOnly"`
+	ChildOnly         []string           `json:"childOnly"`
+	Mismatch          bool               `json:"mismatch"`
+	Servers           []DelegationServer `json:"servers"`
+	Lame              []string           `json:"lame"`
+}
+
+// TraceStep contains a query sent to a nameserver while resolving a name iteratively from the root
+type TraceStep struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Zone      string   `json:"zone"`
+	Server    string   `json:"server"`
+	Address   string   `json:"address"`
+	Rcode     string   `json:"rcode,omitempty"`
+	RTT       string   `json:"rtt"`
+	Answer    []string `json:"answer,omitempty"`
+	Authority []string `json:"authority,omitempty"`
+	Error     string   `json:"error,omitempty"`
 }